@@ -0,0 +1,226 @@
+package spannerdriver
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"github.com/pkg/errors"
+)
+
+type batchKind int
+
+const (
+	batchDDL batchKind = iota + 1
+	batchDML
+)
+
+// batch buffers the statements executed between START BATCH and RUN BATCH.
+type batch struct {
+	kind batchKind
+	ddl  []string
+	dml  []spanner.Statement
+}
+
+// BatchDDLError is returned by RUN BATCH when a statement of a DDL batch fails.
+// The statements of a batch are applied in order, so the statements before the
+// failed one have been applied, and the statements after it have not.
+// Other errors, such as the context being done while waiting for the batch,
+// are returned as they are, as the statements may still be applied.
+type BatchDDLError struct {
+	// Statements are the statements of the batch.
+	Statements []string
+	// Applied is the number of statements which were applied.
+	Applied int
+	// Err is the error of the failed statement.
+	Err error
+}
+
+func (e *BatchDDLError) Error() string {
+	if e.Applied < len(e.Statements) {
+		return fmt.Sprintf("statement %d of DDL batch failed: %q: %v", e.Applied+1, e.Statements[e.Applied], e.Err)
+	}
+	return fmt.Sprintf("DDL batch failed: %v", e.Err)
+}
+
+func (e *BatchDDLError) Unwrap() error {
+	return e.Err
+}
+
+// FailedStatement returns the statement which failed, or an empty string if
+// the batch failed for another reason.
+func (e *BatchDDLError) FailedStatement() string {
+	if e.Applied < len(e.Statements) {
+		return e.Statements[e.Applied]
+	}
+	return ""
+}
+
+// BatchDMLError is returned by RUN BATCH and BatchDML when a statement of a DML
+// batch fails. Cloud Spanner executes the statements of a batch in order and
+// stops at the first one which fails, so RowsAffected has the row counts of
+// the statements which were executed before the failure.
+//
+// In a read-write transaction these statements are part of the transaction.
+// Outside a transaction the batch is executed in a new transaction, which is
+// rolled back, so none of them are applied.
+type BatchDMLError struct {
+	// Statements are the statements of the batch.
+	Statements []spanner.Statement
+	// RowsAffected are the number of rows affected by each statement which
+	// was executed before the failure.
+	RowsAffected []int64
+	// Err is the error of the batch.
+	Err error
+}
+
+func (e *BatchDMLError) Error() string {
+	return fmt.Sprintf("DML batch failed after %d of %d statements: %v", len(e.RowsAffected), len(e.Statements), e.Err)
+}
+
+func (e *BatchDMLError) Unwrap() error {
+	return e.Err
+}
+
+func (c *spannerConn) startBatchDDL(ctx context.Context, _ []string) (driver.Result, error) {
+	if c.batch != nil {
+		return nil, ErrBatchActive
+	}
+	if c.inTransaction() {
+		return nil, ErrDDLInTransaction
+	}
+	c.batch = &batch{kind: batchDDL}
+	return &spannerResult{}, nil
+}
+
+// startBatchDML starts a DML batch, which is executed in the current
+// read-write transaction, or in a new one if there is none.
+func (c *spannerConn) startBatchDML(ctx context.Context, _ []string) (driver.Result, error) {
+	if c.batch != nil {
+		return nil, ErrBatchActive
+	}
+	if err := c.checkWritable(); err != nil {
+		return nil, err
+	}
+	c.batch = &batch{kind: batchDML}
+	return &spannerResult{}, nil
+}
+
+// runBatch executes the statements of the active batch and ends the batch,
+// even if they fail.
+func (c *spannerConn) runBatch(ctx context.Context, _ []string) (driver.Result, error) {
+	if c.batch == nil {
+		return nil, ErrNoActiveBatch
+	}
+	b := c.batch
+	c.batch = nil
+
+	if b.kind == batchDML {
+		result, err := c.batchUpdate(ctx, b.dml)
+		if err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+	if err := c.runBatchDDL(ctx, b.ddl); err != nil {
+		return nil, err
+	}
+	return &spannerResult{}, nil
+}
+
+func (c *spannerConn) runBatchDDL(ctx context.Context, statements []string) error {
+	if len(statements) == 0 {
+		return nil
+	}
+	return c.updateDDL(ctx, statements)
+}
+
+// abortBatch ends the active batch without executing its statements.
+func (c *spannerConn) abortBatch(ctx context.Context, _ []string) (driver.Result, error) {
+	if c.batch == nil {
+		return nil, ErrNoActiveBatch
+	}
+	c.batch = nil
+	return &spannerResult{}, nil
+}
+
+// batchUpdate executes the statements with a single BatchUpdate request in the
+// current read-write transaction, or in a new one if there is none.
+func (c *spannerConn) batchUpdate(ctx context.Context, statements []spanner.Statement) (*spannerResult, error) {
+	if len(statements) == 0 {
+		return newBatchResult(nil), nil
+	}
+	if c.rwTx != nil {
+		counts, err := c.rwTx.BatchUpdate(ctx, statements)
+		if err != nil {
+			return nil, &BatchDMLError{Statements: statements, RowsAffected: counts, Err: wrapError(err)}
+		}
+		return newBatchResult(counts), nil
+	}
+
+	var counts []int64
+	var batchErr error
+	fn := func(ctx context.Context, tx *spanner.ReadWriteTransaction) error {
+		counts, batchErr = tx.BatchUpdate(ctx, statements)
+		return batchErr
+	}
+	c.commitTimestamp = time.Time{}
+	commitTimestamp, err := c.client.ReadWriteTransaction(ctx, fn)
+	if err != nil {
+		if batchErr != nil {
+			return nil, &BatchDMLError{Statements: statements, RowsAffected: counts, Err: wrapError(err)}
+		}
+		return nil, wrapError(err)
+	}
+	c.commitTimestamp = commitTimestamp
+	return newBatchResult(counts), nil
+}
+
+// BatchDML executes the statements as a single batch on conn, in its current
+// read-write transaction if there is one, or in a new one. Use conn.BeginTx to
+// execute the batch in a transaction. The result has the number of rows
+// affected by each statement. If a statement fails, the error is a
+// *BatchDMLError with the row counts of the statements before it.
+func BatchDML(ctx context.Context, conn *sql.Conn, statements []spanner.Statement) (SpannerResult, error) {
+	var result SpannerResult
+	err := conn.Raw(func(driverConn interface{}) error {
+		c, ok := driverConn.(SpannerConn)
+		if !ok {
+			return errors.Errorf("%T is not a spanner connection", driverConn)
+		}
+		var err error
+		result, err = c.BatchDML(ctx, statements)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// BatchDML implements SpannerConn interface.
+func (c *spannerConn) BatchDML(ctx context.Context, statements []spanner.Statement) (SpannerResult, error) {
+	if c.closed.IsSet() {
+		errLog.Print(ErrInvalidConn)
+		return nil, driver.ErrBadConn
+	}
+	if err := c.watchCancel(ctx); err != nil {
+		return nil, err
+	}
+	defer c.finish()
+
+	if c.batch != nil {
+		return nil, ErrBatchActive
+	}
+	if err := c.checkWritable(); err != nil {
+		return nil, err
+	}
+	result, err := c.batchUpdate(ctx, statements)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,159 @@
+package spannerdriver
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"testing"
+
+	"cloud.google.com/go/spanner"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func newTestConn() *spannerConn {
+	return &spannerConn{closech: make(chan struct{})}
+}
+
+func TestBatchDDL(t *testing.T) {
+	ctx := context.Background()
+	c := newTestConn()
+
+	if _, err := c.ExecContext(ctx, "RUN BATCH", nil); err != ErrNoActiveBatch {
+		t.Errorf("expected ErrNoActiveBatch, got %v", err)
+	}
+	if _, err := c.ExecContext(ctx, "START BATCH DDL", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ExecContext(ctx, "START BATCH DDL", nil); err != ErrBatchActive {
+		t.Errorf("expected ErrBatchActive, got %v", err)
+	}
+
+	statements := []string{
+		"CREATE TABLE Users (Id INT64) PRIMARY KEY (Id)",
+		"CREATE INDEX UsersById ON Users (Id)",
+	}
+	for _, s := range statements {
+		if _, err := c.ExecContext(ctx, s, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := c.ExecContext(ctx, "INSERT INTO Users (Id) VALUES (1)", nil); err == nil {
+		t.Error("expected error for DML in a DDL batch, got nil")
+	}
+	if _, err := c.QueryContext(ctx, "SELECT 1", nil); err == nil {
+		t.Error("expected error for a query in a DDL batch, got nil")
+	}
+	if _, err := c.BeginTx(ctx, driver.TxOptions{}); err == nil {
+		t.Error("expected error for a transaction in a DDL batch, got nil")
+	}
+	if !reflect.DeepEqual(c.batch.ddl, statements) {
+		t.Errorf("expected %v to be buffered, got %v", statements, c.batch.ddl)
+	}
+
+	if _, err := c.ExecContext(ctx, "ABORT BATCH", nil); err != nil {
+		t.Fatal(err)
+	}
+	if c.batch != nil {
+		t.Error("expected ABORT BATCH to end the batch")
+	}
+}
+
+func TestBatchDML(t *testing.T) {
+	ctx := context.Background()
+	c := newTestConn()
+
+	if _, err := c.ExecContext(ctx, "START BATCH DML", nil); err != nil {
+		t.Fatal(err)
+	}
+	args := []driver.NamedValue{{Ordinal: 1, Value: int64(1)}}
+	if _, err := c.ExecContext(ctx, "INSERT INTO Users (Id) VALUES (?)", args); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ExecContext(ctx, "CREATE INDEX UsersById ON Users (Id)", nil); err == nil {
+		t.Error("expected error for DDL in a DML batch, got nil")
+	}
+	if _, err := c.QueryContext(ctx, "SELECT 1", nil); err == nil {
+		t.Error("expected error for a query in a DML batch, got nil")
+	}
+	if _, err := c.BatchDML(ctx, nil); err != ErrBatchActive {
+		t.Errorf("expected ErrBatchActive, got %v", err)
+	}
+	want := []spanner.Statement{{SQL: "INSERT INTO Users (Id) VALUES (@p1)", Params: map[string]interface{}{"p1": int64(1)}}}
+	if !reflect.DeepEqual(c.batch.dml, want) {
+		t.Errorf("expected %v to be buffered, got %v", want, c.batch.dml)
+	}
+
+	// An empty batch is not sent to Cloud Spanner.
+	if _, err := c.ExecContext(ctx, "ABORT BATCH", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ExecContext(ctx, "START BATCH DML", nil); err != nil {
+		t.Fatal(err)
+	}
+	res, err := c.ExecContext(ctx, "RUN BATCH", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counts, err := res.(SpannerResult).BatchRowsAffected(); err != nil || len(counts) != 0 {
+		t.Errorf("expected no row counts, got %v, %v", counts, err)
+	}
+
+	c.readOnly = true
+	if _, err := c.ExecContext(ctx, "START BATCH DML", nil); err != ErrWriteInReadOnlyConnection {
+		t.Errorf("expected ErrWriteInReadOnlyConnection, got %v", err)
+	}
+}
+
+func TestBatchDDLError(t *testing.T) {
+	cause := errors.New("Duplicate name in schema: Users")
+	err := error(&BatchDDLError{
+		Statements: []string{"CREATE TABLE A (Id INT64) PRIMARY KEY (Id)", "CREATE TABLE Users (Id INT64) PRIMARY KEY (Id)"},
+		Applied:    1,
+		Err:        cause,
+	})
+	if !errors.Is(err, cause) {
+		t.Error("expected BatchDDLError to wrap the error of the failed statement")
+	}
+	var batchErr *BatchDDLError
+	if !errors.As(err, &batchErr) {
+		t.Fatal("expected errors.As to find BatchDDLError")
+	}
+	if s := batchErr.FailedStatement(); s != "CREATE TABLE Users (Id INT64) PRIMARY KEY (Id)" {
+		t.Errorf("unexpected failed statement: %q", s)
+	}
+	want := `statement 2 of DDL batch failed: "CREATE TABLE Users (Id INT64) PRIMARY KEY (Id)": Duplicate name in schema: Users`
+	if err.Error() != want {
+		t.Errorf("expected %q, got %q", want, err.Error())
+	}
+}
+
+func TestBatchDMLError(t *testing.T) {
+	ctx := context.Background()
+	c := newTestConn()
+	cause := spanner.ToSpannerError(status.Error(codes.AlreadyExists, "Row [1] in table Users already exists"))
+	db := &fakeDatabase{batchErr: cause, updateCount: func(int) int64 { return 1 }}
+	fake, _ := db.begin(ctx)
+	c.rwTx = &readWriteTransaction{tx: fake}
+
+	statements := []spanner.Statement{
+		{SQL: "INSERT INTO Users (Id) VALUES (2)"},
+		{SQL: "INSERT INTO Users (Id) VALUES (1)"},
+	}
+	_, err := c.BatchDML(ctx, statements)
+	var batchErr *BatchDMLError
+	if !errors.As(err, &batchErr) {
+		t.Fatalf("expected BatchDMLError, got %v", err)
+	}
+	if !reflect.DeepEqual(batchErr.RowsAffected, []int64{1}) {
+		t.Errorf("expected the row count of the first statement, got %v", batchErr.RowsAffected)
+	}
+	if !errors.Is(err, cause) {
+		t.Errorf("expected BatchDMLError to wrap the error of the failed statement, got %v", err)
+	}
+	want := "DML batch failed after 1 of 2 statements: " + wrapError(cause).Error()
+	if err.Error() != want {
+		t.Errorf("expected %q, got %q", want, err.Error())
+	}
+}
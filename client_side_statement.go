@@ -0,0 +1,197 @@
+package spannerdriver
+
+import (
+	"context"
+	"database/sql/driver"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// clientSideStatement is a statement which is executed by the driver itself
+// instead of being sent to Cloud Spanner, e.g. START BATCH DDL. It is either
+// executed with exec or queried with query.
+type clientSideStatement struct {
+	name  string
+	re    *regexp.Regexp
+	exec  func(c *spannerConn, ctx context.Context, params []string) (driver.Result, error)
+	query func(c *spannerConn, ctx context.Context, params []string) (driver.Rows, error)
+}
+
+// newClientSideStatementRegexp returns a regexp matching the whole statement
+// case-insensitively, with any whitespace between words and an optional ';'.
+func newClientSideStatementRegexp(expr string) *regexp.Regexp {
+	return regexp.MustCompile(`(?is)^\s*` + expr + `\s*;?\s*$`)
+}
+
+var clientSideStatements = []*clientSideStatement{
+	{
+		name: "START BATCH DDL",
+		re:   newClientSideStatementRegexp(`START\s+BATCH\s+DDL`),
+		exec: (*spannerConn).startBatchDDL,
+	},
+	{
+		name: "START BATCH DML",
+		re:   newClientSideStatementRegexp(`START\s+BATCH\s+DML`),
+		exec: (*spannerConn).startBatchDML,
+	},
+	{
+		name: "RUN BATCH",
+		re:   newClientSideStatementRegexp(`RUN\s+BATCH`),
+		exec: (*spannerConn).runBatch,
+	},
+	{
+		name: "ABORT BATCH",
+		re:   newClientSideStatementRegexp(`ABORT\s+BATCH`),
+		exec: (*spannerConn).abortBatch,
+	},
+	{
+		name: "SET AUTOCOMMIT_DML_MODE",
+		re:   newClientSideStatementRegexp(`SET\s+AUTOCOMMIT_DML_MODE\s*=\s*'([^']*)'`),
+		exec: (*spannerConn).setAutocommitDMLMode,
+	},
+	{
+		name: "SET READ_ONLY_STALENESS",
+		re:   newClientSideStatementRegexp(`SET\s+READ_ONLY_STALENESS\s*=\s*'([^']*)'`),
+		exec: (*spannerConn).setReadOnlyStaleness,
+	},
+	{
+		name: "SET RETRY_ABORTS_INTERNALLY",
+		re:   newClientSideStatementRegexp(`SET\s+RETRY_ABORTS_INTERNALLY\s*=\s*(\w+)`),
+		exec: (*spannerConn).setRetryAbortsInternally,
+	},
+	{
+		name:  "SHOW VARIABLE",
+		re:    newClientSideStatementRegexp(`SHOW\s+VARIABLE\s+(\w+)`),
+		query: (*spannerConn).showVariable,
+	},
+}
+
+// parseClientSideStatement returns the client-side statement which query is,
+// and the values of its parameters, or nil if query is not a client-side statement.
+func parseClientSideStatement(query string) (*clientSideStatement, []string) {
+	for _, s := range clientSideStatements {
+		if m := s.re.FindStringSubmatch(query); m != nil {
+			return s, m[1:]
+		}
+	}
+	return nil, nil
+}
+
+func (c *spannerConn) execClientSideStatement(ctx context.Context, s *clientSideStatement, params []string, args []driver.NamedValue) (driver.Result, error) {
+	if len(args) > 0 {
+		return nil, errors.Errorf("%s does not support parameters", s.name)
+	}
+	if s.exec == nil {
+		return nil, errors.Errorf("%s must be executed as a query", s.name)
+	}
+	return s.exec(c, ctx, params)
+}
+
+func (c *spannerConn) queryClientSideStatement(ctx context.Context, s *clientSideStatement, params []string, args []driver.NamedValue) (driver.Rows, error) {
+	if len(args) > 0 {
+		return nil, errors.Errorf("%s does not support parameters", s.name)
+	}
+	if s.query == nil {
+		return nil, errors.Errorf("%s must be executed with Exec", s.name)
+	}
+	return s.query(c, ctx, params)
+}
+
+// clientSideRows are the rows returned by a client-side statement.
+type clientSideRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+// Columns implements database/sql/driver.Rows interface.
+func (r *clientSideRows) Columns() []string {
+	return r.columns
+}
+
+// Close implements database/sql/driver.Rows interface.
+func (r *clientSideRows) Close() error {
+	r.rows = nil
+	return nil
+}
+
+// Next implements database/sql/driver.Rows interface.
+func (r *clientSideRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+// setAutocommitDMLMode sets the mode in which DML statements are executed
+// outside a transaction. The mode is reset when database/sql reuses the
+// connection from its pool.
+func (c *spannerConn) setAutocommitDMLMode(ctx context.Context, params []string) (driver.Result, error) {
+	mode, err := parseAutocommitDMLMode(params[0])
+	if err != nil {
+		return nil, err
+	}
+	c.autocommitDMLMode = mode
+	return &spannerResult{}, nil
+}
+
+// setReadOnlyStaleness sets the timestamp bound of read-only transactions and
+// of queries outside a transaction, e.g. 'EXACT_STALENESS 15s'. The staleness
+// is reset to the default of the DSN when database/sql reuses the connection
+// from its pool.
+func (c *spannerConn) setReadOnlyStaleness(ctx context.Context, params []string) (driver.Result, error) {
+	tb, err := parseTimestampBound(params[0])
+	if err != nil {
+		return nil, err
+	}
+	c.staleness = tb
+	return &spannerResult{}, nil
+}
+
+// setRetryAbortsInternally sets whether read-write transactions begun on the
+// connection are retried when Cloud Spanner aborts them. It cannot be changed
+// in a transaction, and is reset to the default of the DSN when database/sql
+// reuses the connection from its pool.
+func (c *spannerConn) setRetryAbortsInternally(ctx context.Context, params []string) (driver.Result, error) {
+	retry, err := strconv.ParseBool(params[0])
+	if err != nil {
+		return nil, errors.Errorf("invalid RETRY_ABORTS_INTERNALLY value: %q", params[0])
+	}
+	if c.inTransaction() {
+		return nil, errors.New("cannot set RETRY_ABORTS_INTERNALLY in a transaction")
+	}
+	c.retryAborts = retry
+	return &spannerResult{}, nil
+}
+
+// showVariable returns a single row with the value of a variable of the
+// connection, which is NULL if the value is not available:
+//
+//	COMMIT_TIMESTAMP         commit timestamp of the last read-write transaction
+//	READ_TIMESTAMP           read timestamp of the last read-only transaction or
+//	                         query outside a transaction
+//	RETRY_ABORTS_INTERNALLY  whether aborted read-write transactions are retried
+func (c *spannerConn) showVariable(ctx context.Context, params []string) (driver.Rows, error) {
+	name := strings.ToUpper(params[0])
+	var value driver.Value
+	switch name {
+	case "COMMIT_TIMESTAMP":
+		if ts, err := c.CommitTimestamp(); err == nil {
+			value = ts
+		}
+	case "READ_TIMESTAMP":
+		if ts, err := c.ReadTimestamp(); err == nil {
+			value = ts
+		}
+	case "RETRY_ABORTS_INTERNALLY":
+		value = c.retryAborts
+	default:
+		return nil, errors.Errorf("unknown variable: %s", params[0])
+	}
+	return &clientSideRows{columns: []string{name}, rows: [][]driver.Value{{value}}}, nil
+}
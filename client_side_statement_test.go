@@ -0,0 +1,102 @@
+package spannerdriver
+
+import (
+	"context"
+	"database/sql/driver"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var _ driver.Rows = &clientSideRows{}
+
+func TestParseClientSideStatement(t *testing.T) {
+	tests := []struct {
+		query  string
+		name   string
+		params []string
+	}{
+		{"START BATCH DDL", "START BATCH DDL", []string{}},
+		{"  start batch\n ddl; ", "START BATCH DDL", []string{}},
+		{"START BATCH DML", "START BATCH DML", []string{}},
+		{"RUN BATCH", "RUN BATCH", []string{}},
+		{"Abort Batch;", "ABORT BATCH", []string{}},
+		{"SET AUTOCOMMIT_DML_MODE = 'PARTITIONED_NON_ATOMIC'", "SET AUTOCOMMIT_DML_MODE", []string{"PARTITIONED_NON_ATOMIC"}},
+		{"set autocommit_dml_mode='Transactional';", "SET AUTOCOMMIT_DML_MODE", []string{"Transactional"}},
+		{"SET READ_ONLY_STALENESS = 'EXACT_STALENESS 15s'", "SET READ_ONLY_STALENESS", []string{"EXACT_STALENESS 15s"}},
+		{"SET RETRY_ABORTS_INTERNALLY = true", "SET RETRY_ABORTS_INTERNALLY", []string{"true"}},
+		{"SHOW VARIABLE commit_timestamp", "SHOW VARIABLE", []string{"commit_timestamp"}},
+		{"START BATCH", "", nil},
+		{"SET AUTOCOMMIT_DML_MODE = PARTITIONED_NON_ATOMIC", "", nil},
+		{"RUN BATCH NOW", "", nil},
+		{"SELECT 'RUN BATCH'", "", nil},
+	}
+	for _, tt := range tests {
+		s, params := parseClientSideStatement(tt.query)
+		if tt.name == "" {
+			if s != nil {
+				t.Errorf("%q: expected no client-side statement, got %s", tt.query, s.name)
+			}
+			continue
+		}
+		if s == nil || s.name != tt.name {
+			t.Errorf("%q: expected %s, got %v", tt.query, tt.name, s)
+			continue
+		}
+		if !reflect.DeepEqual(params, tt.params) {
+			t.Errorf("%q: expected params %v, got %v", tt.query, tt.params, params)
+		}
+	}
+}
+
+func TestShowVariable(t *testing.T) {
+	ctx := context.Background()
+	c := newTestConn()
+
+	show := func(query string) driver.Value {
+		t.Helper()
+		rows, err := c.QueryContext(ctx, query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rows.Close()
+		dest := make([]driver.Value, 1)
+		if err := rows.Next(dest); err != nil {
+			t.Fatal(err)
+		}
+		if err := rows.Next(dest); err != io.EOF {
+			t.Errorf("expected a single row, got %v", err)
+		}
+		return dest[0]
+	}
+
+	if v := show("SHOW VARIABLE COMMIT_TIMESTAMP"); v != nil {
+		t.Errorf("expected NULL before a commit, got %v", v)
+	}
+	if v := show("SHOW VARIABLE READ_TIMESTAMP"); v != nil {
+		t.Errorf("expected NULL before a read, got %v", v)
+	}
+	if _, err := c.CommitTimestamp(); err == nil {
+		t.Error("expected error before a commit, got nil")
+	}
+
+	ts := time.Date(2021, 11, 4, 10, 0, 0, 0, time.UTC)
+	c.commitTimestamp = ts
+	if v := show("show variable commit_timestamp;"); v != ts {
+		t.Errorf("expected %s, got %v", ts, v)
+	}
+	if got, err := c.CommitTimestamp(); err != nil || got != ts {
+		t.Errorf("expected %s, got %s, %v", ts, got, err)
+	}
+
+	if _, err := c.QueryContext(ctx, "SHOW VARIABLE UNKNOWN", nil); err == nil {
+		t.Error("expected error for unknown variable, got nil")
+	}
+	if _, err := c.ExecContext(ctx, "SHOW VARIABLE COMMIT_TIMESTAMP", nil); err == nil {
+		t.Error("expected error for SHOW VARIABLE executed with Exec, got nil")
+	}
+	if _, err := c.QueryContext(ctx, "START BATCH DDL", nil); err == nil {
+		t.Error("expected error for START BATCH DDL executed as a query, got nil")
+	}
+}
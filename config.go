@@ -1,13 +1,48 @@
 package spannerdriver
 
 import (
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
 	"cloud.google.com/go/spanner"
+	"github.com/pkg/errors"
 	"google.golang.org/api/option"
+	"google.golang.org/api/option/internaloption"
+	"google.golang.org/grpc"
 )
 
 type Config struct {
 	Database string
 
+	// Credentials is the path of a service account key file.
+	Credentials string
+	// NumChannels is the number of gRPC channels of the client.
+	NumChannels int
+	// MinSessions and MaxSessions configure the session pool of the client.
+	MinSessions uint64
+	MaxSessions uint64
+	// ReadOnly makes the connections read-only. Transactions are started as
+	// read-only transactions, and writing outside a transaction fails with
+	// ErrWriteInReadOnlyConnection.
+	ReadOnly bool
+	// ReadOnlyStaleness is the default timestamp bound of read-only
+	// transactions and of queries outside a transaction, e.g.
+	// "EXACT_STALENESS 15s". See SET READ_ONLY_STALENESS for the format.
+	// Reads are strong by default. MAX_STALENESS and MIN_READ_TIMESTAMP only
+	// apply to queries outside a transaction, and beginning a read-only
+	// transaction with them fails with ErrBoundedStalenessInTransaction.
+	ReadOnlyStaleness string
+	// RetryAbortsInternally makes the connections retry read-write
+	// transactions aborted by Cloud Spanner by replaying their statements.
+	// See SET RETRY_ABORTS_INTERNALLY.
+	RetryAbortsInternally bool
+	// EmulatorHost is the address of a Cloud Spanner emulator to connect to.
+	EmulatorHost string
+	// UserAgent is prepended to the user agent of the driver.
+	UserAgent string
+
 	ClientConfig  spanner.ClientConfig
 	ClientOptions []option.ClientOption
 }
@@ -18,3 +53,164 @@ func NewConfig(database string) *Config {
 		ClientOptions: make([]option.ClientOption, 0),
 	}
 }
+
+var databasePathRegex = regexp.MustCompile("^projects/[^/]+/instances/[^/]+/databases/[^/]+$")
+
+// ParseDSN parses a DSN of the form
+//
+//	projects/PROJECT/instances/INSTANCE/databases/DATABASE[?param1=value1&...&paramN=valueN]
+//
+// The following parameters are supported:
+//
+//	credentials            path of a service account key file
+//	numChannels            number of gRPC channels
+//	minSessions            minimum number of sessions in the session pool
+//	maxSessions            maximum number of sessions in the session pool
+//	readOnly               true to make every transaction read-only
+//	readOnlyStaleness      default timestamp bound of reads, e.g. EXACT_STALENESS 15s
+//	retryAbortsInternally  true to retry aborted read-write transactions
+//	emulatorHost           address of a Cloud Spanner emulator, e.g. localhost:9010
+//	userAgent              prepended to the user agent of the driver
+//
+// Parameter values must be escaped as URL query values.
+func ParseDSN(dsn string) (*Config, error) {
+	database, query := dsn, ""
+	if i := strings.IndexByte(dsn, '?'); i >= 0 {
+		database, query = dsn[:i], dsn[i+1:]
+	}
+	if !databasePathRegex.MatchString(database) {
+		return nil, errors.Errorf("invalid database path %q: must be projects/PROJECT/instances/INSTANCE/databases/DATABASE", database)
+	}
+	cfg := NewConfig(database)
+
+	params, err := url.ParseQuery(query)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid DSN parameters")
+	}
+	for key, values := range params {
+		value := values[len(values)-1]
+		switch key {
+		case "credentials":
+			cfg.Credentials = value
+		case "numChannels":
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 {
+				return nil, errors.Errorf("invalid numChannels value: %q", value)
+			}
+			cfg.NumChannels = n
+		case "minSessions":
+			if cfg.MinSessions, err = strconv.ParseUint(value, 10, 64); err != nil {
+				return nil, errors.Errorf("invalid minSessions value: %q", value)
+			}
+		case "maxSessions":
+			if cfg.MaxSessions, err = strconv.ParseUint(value, 10, 64); err != nil {
+				return nil, errors.Errorf("invalid maxSessions value: %q", value)
+			}
+		case "readOnly":
+			if cfg.ReadOnly, err = strconv.ParseBool(value); err != nil {
+				return nil, errors.Errorf("invalid readOnly value: %q", value)
+			}
+		case "readOnlyStaleness":
+			if _, err := parseTimestampBound(value); err != nil {
+				return nil, err
+			}
+			cfg.ReadOnlyStaleness = value
+		case "retryAbortsInternally":
+			if cfg.RetryAbortsInternally, err = strconv.ParseBool(value); err != nil {
+				return nil, errors.Errorf("invalid retryAbortsInternally value: %q", value)
+			}
+		case "emulatorHost":
+			cfg.EmulatorHost = value
+		case "userAgent":
+			cfg.UserAgent = value
+		default:
+			return nil, errors.Errorf("unknown DSN parameter: %s", key)
+		}
+	}
+	return cfg, nil
+}
+
+// FormatDSN formats the config as a DSN which can be parsed by ParseDSN.
+// ClientConfig and ClientOptions cannot be represented in a DSN and are ignored.
+func (cfg *Config) FormatDSN() string {
+	params := url.Values{}
+	if cfg.Credentials != "" {
+		params.Set("credentials", cfg.Credentials)
+	}
+	if cfg.NumChannels != 0 {
+		params.Set("numChannels", strconv.Itoa(cfg.NumChannels))
+	}
+	if cfg.MinSessions != 0 {
+		params.Set("minSessions", strconv.FormatUint(cfg.MinSessions, 10))
+	}
+	if cfg.MaxSessions != 0 {
+		params.Set("maxSessions", strconv.FormatUint(cfg.MaxSessions, 10))
+	}
+	if cfg.ReadOnly {
+		params.Set("readOnly", "true")
+	}
+	if cfg.ReadOnlyStaleness != "" {
+		params.Set("readOnlyStaleness", cfg.ReadOnlyStaleness)
+	}
+	if cfg.RetryAbortsInternally {
+		params.Set("retryAbortsInternally", "true")
+	}
+	if cfg.EmulatorHost != "" {
+		params.Set("emulatorHost", cfg.EmulatorHost)
+	}
+	if cfg.UserAgent != "" {
+		params.Set("userAgent", cfg.UserAgent)
+	}
+
+	if len(params) == 0 {
+		return cfg.Database
+	}
+	return cfg.Database + "?" + params.Encode()
+}
+
+// timestampBound returns the timestamp bound of ReadOnlyStaleness.
+func (cfg *Config) timestampBound() (spanner.TimestampBound, error) {
+	if cfg.ReadOnlyStaleness == "" {
+		return spanner.StrongRead(), nil
+	}
+	return parseTimestampBound(cfg.ReadOnlyStaleness)
+}
+
+// clientConfig returns the spanner.ClientConfig with the parameters of the
+// config applied on top of ClientConfig.
+func (cfg *Config) clientConfig() spanner.ClientConfig {
+	config := cfg.ClientConfig
+	if cfg.NumChannels != 0 {
+		config.NumChannels = cfg.NumChannels
+	}
+	if cfg.MinSessions != 0 {
+		config.MinOpened = cfg.MinSessions
+	}
+	if cfg.MaxSessions != 0 {
+		config.MaxOpened = cfg.MaxSessions
+	}
+	return config
+}
+
+// clientOptions returns ClientOptions with the options for the parameters
+// of the config appended.
+func (cfg *Config) clientOptions() []option.ClientOption {
+	ua := userAgent
+	if cfg.UserAgent != "" {
+		ua = cfg.UserAgent + " " + userAgent
+	}
+	opts := append([]option.ClientOption{}, cfg.ClientOptions...)
+	opts = append(opts, option.WithUserAgent(ua))
+	if cfg.Credentials != "" {
+		opts = append(opts, option.WithCredentialsFile(cfg.Credentials))
+	}
+	if cfg.EmulatorHost != "" {
+		opts = append(opts,
+			option.WithEndpoint(cfg.EmulatorHost),
+			option.WithGRPCDialOption(grpc.WithInsecure()),
+			option.WithoutAuthentication(),
+			internaloption.SkipDialSettingsValidation(),
+		)
+	}
+	return opts
+}
@@ -0,0 +1,87 @@
+package spannerdriver
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseDSN(t *testing.T) {
+	const database = "projects/p/instances/i/databases/d"
+	tests := []struct {
+		dsn  string
+		want *Config
+	}{
+		{database, &Config{Database: database}},
+		{
+			database + "?credentials=%2Fpath.json&numChannels=8&minSessions=100&maxSessions=400&readOnly=true&readOnlyStaleness=EXACT_STALENESS+15s&retryAbortsInternally=true&emulatorHost=localhost%3A9010&userAgent=my-service",
+			&Config{
+				Database:              database,
+				Credentials:           "/path.json",
+				NumChannels:           8,
+				MinSessions:           100,
+				MaxSessions:           400,
+				ReadOnly:              true,
+				ReadOnlyStaleness:     "EXACT_STALENESS 15s",
+				RetryAbortsInternally: true,
+				EmulatorHost:          "localhost:9010",
+				UserAgent:             "my-service",
+			},
+		},
+		{database + "?emulatorHost=localhost:9010", &Config{Database: database, EmulatorHost: "localhost:9010"}},
+		{database + "?readOnlyStaleness=MAX_STALENESS+15s", &Config{Database: database, ReadOnlyStaleness: "MAX_STALENESS 15s"}},
+	}
+	for _, tt := range tests {
+		cfg, err := ParseDSN(tt.dsn)
+		if err != nil {
+			t.Errorf("%s: ParseDSN returned error: %+v", tt.dsn, err)
+			continue
+		}
+		cfg.ClientOptions = nil
+		if !reflect.DeepEqual(cfg, tt.want) {
+			t.Errorf("%s: expected %+v, got %+v", tt.dsn, tt.want, cfg)
+		}
+
+		// FormatDSN must round-trip.
+		cfg2, err := ParseDSN(cfg.FormatDSN())
+		if err != nil {
+			t.Errorf("%s: ParseDSN(FormatDSN()) returned error: %+v", tt.dsn, err)
+			continue
+		}
+		cfg2.ClientOptions = nil
+		if !reflect.DeepEqual(cfg2, cfg) {
+			t.Errorf("%s: expected %+v after round-trip, got %+v", tt.dsn, cfg, cfg2)
+		}
+	}
+}
+
+func TestParseDSNError(t *testing.T) {
+	for _, dsn := range []string{
+		"",
+		"testdb",
+		"projects/p/instances/i/databases/",
+		"projects/p/instances/i/databases/d?unknown=1",
+		"projects/p/instances/i/databases/d?numChannels=0",
+		"projects/p/instances/i/databases/d?minSessions=-1",
+		"projects/p/instances/i/databases/d?readOnly=maybe",
+		"projects/p/instances/i/databases/d?readOnlyStaleness=EXACT_STALENESS",
+		"projects/p/instances/i/databases/d?retryAbortsInternally=maybe",
+		"projects/p/instances/i/databases/d?userAgent=%zz",
+	} {
+		if _, err := ParseDSN(dsn); err == nil {
+			t.Errorf("%s: expected error, got nil", dsn)
+		}
+	}
+}
+
+func TestFormatDSN(t *testing.T) {
+	cfg := NewConfig("projects/p/instances/i/databases/d")
+	if got := cfg.FormatDSN(); got != cfg.Database {
+		t.Errorf("expected %s, got %s", cfg.Database, got)
+	}
+
+	cfg.NumChannels = 4
+	cfg.ReadOnly = true
+	if want, got := "projects/p/instances/i/databases/d?numChannels=4&readOnly=true", cfg.FormatDSN(); got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
@@ -3,6 +3,7 @@ package spannerdriver
 import (
 	"context"
 	"database/sql/driver"
+	"time"
 
 	"cloud.google.com/go/spanner"
 	"github.com/pkg/errors"
@@ -10,11 +11,61 @@ import (
 	"google.golang.org/api/iterator"
 )
 
+// SpannerConn is implemented by the connections of the driver to give access
+// to features of Cloud Spanner which database/sql does not support. Use it
+// through sql.Conn.Raw:
+//
+//	err := conn.Raw(func(driverConn interface{}) error {
+//		return driverConn.(spannerdriver.SpannerConn).BufferWrite(mutations)
+//	})
+type SpannerConn interface {
+	// BufferWrite buffers the mutations in the current read-write transaction,
+	// which applies them on commit together with the DML statements of the
+	// transaction. It fails outside a read-write transaction.
+	BufferWrite(ms []*spanner.Mutation) error
+	// Apply applies the mutations in a new read-write transaction and returns
+	// its commit timestamp. It fails in a transaction, use BufferWrite instead.
+	Apply(ctx context.Context, ms []*spanner.Mutation) (commitTimestamp time.Time, err error)
+	// CommitTimestamp returns the commit timestamp of the last read-write
+	// transaction committed on the connection, including the transactions of
+	// DML statements and mutations outside a transaction.
+	// The same value is returned by SHOW VARIABLE COMMIT_TIMESTAMP.
+	CommitTimestamp() (time.Time, error)
+	// ReadTimestamp returns the read timestamp of the last read-only
+	// transaction or of the last query outside a transaction.
+	// The same value is returned by SHOW VARIABLE READ_TIMESTAMP.
+	ReadTimestamp() (time.Time, error)
+	// BatchDML executes the DML statements as a single batch, in the current
+	// read-write transaction or in a new one, and returns a result with the
+	// number of rows affected by each statement. See the BatchDML function.
+	BatchDML(ctx context.Context, statements []spanner.Statement) (SpannerResult, error)
+}
+
 type spannerConn struct {
-	client *spanner.Client
+	client   *spanner.Client
+	admin    *databaseAdmin
+	readOnly bool
+	// release is called on close when the client is shared by the driver.
+	release func()
 
 	roTx *spanner.ReadOnlyTransaction
-	rwTx *spanner.ReadWriteStmtBasedTransaction
+	rwTx *readWriteTransaction
+	// batch is the batch started by START BATCH, if any.
+	batch *batch
+	// autocommitDMLMode is set by SET AUTOCOMMIT_DML_MODE.
+	autocommitDMLMode AutocommitDMLMode
+	// staleness is the timestamp bound of read-only transactions and of
+	// queries outside a transaction, set by SET READ_ONLY_STALENESS.
+	staleness        spanner.TimestampBound
+	defaultStaleness spanner.TimestampBound
+	// retryAborts is set by SET RETRY_ABORTS_INTERNALLY.
+	retryAborts        bool
+	defaultRetryAborts bool
+	// commitTimestamp is the commit timestamp of the last read-write
+	// transaction, and lastReadOnlyTx the last read-only transaction, whose
+	// read timestamp is known once it has read.
+	commitTimestamp time.Time
+	lastReadOnlyTx  *spanner.ReadOnlyTransaction
 
 	// for context support (Go 1.8+)
 	watching bool
@@ -23,6 +74,9 @@ type spannerConn struct {
 	finished chan<- struct{}
 	canceled atomicError // set non-nil if conn is canceled
 	closed   atomicBool
+	// bad is set when the session or the client of the connection failed in
+	// a transaction, so that the connection is discarded after it.
+	bad atomicBool
 }
 
 // Prepare implements database/sql/driver.Conn interface
@@ -57,7 +111,13 @@ func (c *spannerConn) cleanup() {
 
 	c.roTx = nil
 	c.rwTx = nil
+	c.batch = nil
+	c.lastReadOnlyTx = nil
 	c.client = nil
+	c.admin = nil
+	if c.release != nil {
+		c.release()
+	}
 }
 
 // Begin implements database/sql/driver.Conn interface
@@ -80,19 +140,27 @@ func (c *spannerConn) BeginTx(ctx context.Context, opts driver.TxOptions) (drive
 	if c.inTransaction() {
 		return nil, errors.New("already in a transaction")
 	}
+	if c.batch != nil {
+		return nil, errors.New("cannot begin a transaction while a batch is active")
+	}
 
-	if opts.ReadOnly {
-		c.roTx = c.client.ReadOnlyTransaction().WithTimestampBound(spanner.StrongRead())
+	if opts.ReadOnly || c.readOnly {
+		if isBoundedStaleness(c.timestampBoundOf(ctx)) {
+			return nil, ErrBoundedStalenessInTransaction
+		}
+		c.roTx = c.client.ReadOnlyTransaction().WithTimestampBound(c.timestampBoundOf(ctx))
+		c.lastReadOnlyTx = c.roTx
 		return &roTx{ctx: ctx, conn: c, close: func() {
 			c.roTx.Close()
 			c.roTx = nil
 		}}, nil
 	}
 
+	c.commitTimestamp = time.Time{}
 	var err error
-	c.rwTx, err = spanner.NewReadWriteStmtBasedTransaction(ctx, c.client)
+	c.rwTx, err = newReadWriteTransaction(ctx, c.client, c.retryAborts)
 	if err != nil {
-		return nil, err
+		return nil, c.badConnError(err)
 	}
 
 	return &rwTx{ctx: ctx, conn: c, close: func() {
@@ -116,22 +184,40 @@ func (c *spannerConn) ExecContext(ctx context.Context, query string, args []driv
 	}
 	defer c.finish()
 
-	if c.roTx != nil {
-		return nil, ErrWriteInReadOnlyTransaction
+	if s, params := parseClientSideStatement(query); s != nil {
+		return c.execClientSideStatement(ctx, s, params, args)
+	}
+	if err := c.checkWritable(); err != nil {
+		return nil, err
+	}
+	if internal.IsDDL(query) {
+		return c.execDDL(ctx, query, args)
+	}
+	if c.batch != nil && c.batch.kind == batchDDL {
+		return nil, errors.New("only DDL statements can be executed in a DDL batch")
 	}
 	ss, err := prepareSpannerStmt(query, args)
 	if err != nil {
 		return nil, err
 	}
+	if c.batch != nil {
+		// The statement is executed by RUN BATCH.
+		c.batch.dml = append(c.batch.dml, ss)
+		return &spannerResult{}, nil
+	}
 
 	var rowsAffected int64
-	if c.rwTx == nil {
-		rowsAffected, err = c.execContextInNewRWTransaction(ctx, ss)
-	} else {
+	if c.rwTx != nil {
 		rowsAffected, err = c.rwTx.Update(ctx, ss)
+	} else if c.autocommitDMLModeOf(ctx) == PartitionedNonAtomic {
+		// Partitioned DML has no commit timestamp.
+		c.commitTimestamp = time.Time{}
+		rowsAffected, err = c.client.PartitionedUpdate(ctx, ss)
+	} else {
+		rowsAffected, err = c.execContextInNewRWTransaction(ctx, ss)
 	}
 	if err != nil {
-		return nil, err
+		return nil, c.badConnError(err)
 	}
 	return &spannerResult{rowsAffected: rowsAffected}, nil
 }
@@ -141,6 +227,68 @@ func (c *spannerConn) QueryContext(ctx context.Context, query string, args []dri
 	return c.query(ctx, query, args)
 }
 
+// CheckNamedValue implements database/sql/driver.NamedValueChecker interface
+func (c *spannerConn) CheckNamedValue(nv *driver.NamedValue) error {
+	return checkNamedValue(nv)
+}
+
+// BufferWrite implements SpannerConn interface.
+func (c *spannerConn) BufferWrite(ms []*spanner.Mutation) error {
+	if c.closed.IsSet() {
+		errLog.Print(ErrInvalidConn)
+		return driver.ErrBadConn
+	}
+	if err := c.checkWritable(); err != nil {
+		return err
+	}
+	if c.rwTx == nil {
+		return errors.New("BufferWrite requires a read-write transaction, use Apply outside a transaction")
+	}
+	return wrapError(c.rwTx.BufferWrite(ms))
+}
+
+// Apply implements SpannerConn interface.
+func (c *spannerConn) Apply(ctx context.Context, ms []*spanner.Mutation) (time.Time, error) {
+	if c.closed.IsSet() {
+		errLog.Print(ErrInvalidConn)
+		return time.Time{}, driver.ErrBadConn
+	}
+	if err := c.watchCancel(ctx); err != nil {
+		return time.Time{}, err
+	}
+	defer c.finish()
+
+	if err := c.checkWritable(); err != nil {
+		return time.Time{}, err
+	}
+	if c.rwTx != nil {
+		return time.Time{}, errors.New("cannot Apply mutations in a transaction, use BufferWrite instead")
+	}
+	c.commitTimestamp = time.Time{}
+	commitTimestamp, err := c.client.Apply(ctx, ms)
+	if err != nil {
+		return time.Time{}, c.badConnError(err)
+	}
+	c.commitTimestamp = commitTimestamp
+	return commitTimestamp, nil
+}
+
+// CommitTimestamp implements SpannerConn interface.
+func (c *spannerConn) CommitTimestamp() (time.Time, error) {
+	if c.commitTimestamp.IsZero() {
+		return time.Time{}, errors.New("no commit timestamp is available")
+	}
+	return c.commitTimestamp, nil
+}
+
+// ReadTimestamp implements SpannerConn interface.
+func (c *spannerConn) ReadTimestamp() (time.Time, error) {
+	if c.lastReadOnlyTx == nil {
+		return time.Time{}, errors.New("no read timestamp is available")
+	}
+	return c.lastReadOnlyTx.Timestamp()
+}
+
 // Ping implements database/sql/driver.Pinger interface
 func (c *spannerConn) Ping(ctx context.Context) (err error) {
 	if c.closed.IsSet() {
@@ -153,7 +301,7 @@ func (c *spannerConn) Ping(ctx context.Context) (err error) {
 	}
 	defer c.finish()
 
-	if c.inTransaction() {
+	if c.inTransaction() || c.batch != nil {
 		return nil
 	}
 
@@ -164,7 +312,7 @@ func (c *spannerConn) Ping(ctx context.Context) (err error) {
 
 // ResetSession implements database/sql/driver.SessionResetter interface
 func (c *spannerConn) ResetSession(ctx context.Context) error {
-	if c.closed.IsSet() {
+	if c.closed.IsSet() || c.bad.IsSet() {
 		return driver.ErrBadConn
 	}
 	if c.roTx != nil {
@@ -175,6 +323,12 @@ func (c *spannerConn) ResetSession(ctx context.Context) error {
 		c.rwTx.Rollback(ctx)
 	}
 	c.rwTx = nil
+	c.batch = nil
+	c.autocommitDMLMode = Transactional
+	c.staleness = c.defaultStaleness
+	c.retryAborts = c.defaultRetryAborts
+	c.commitTimestamp = time.Time{}
+	c.lastReadOnlyTx = nil
 
 	return nil
 }
@@ -182,7 +336,25 @@ func (c *spannerConn) ResetSession(ctx context.Context) error {
 // IsValid implements database/sql/driver.Validator interface
 // (From Go 1.15)
 func (c *spannerConn) IsValid() bool {
-	return !c.closed.IsSet()
+	return !c.closed.IsSet() && !c.bad.IsSet()
+}
+
+// autocommitDMLModeOf returns the autocommit DML mode set on ctx, or the mode
+// of the connection if there is none.
+func (c *spannerConn) autocommitDMLModeOf(ctx context.Context) AutocommitDMLMode {
+	if mode, ok := autocommitDMLModeFromContext(ctx); ok {
+		return mode
+	}
+	return c.autocommitDMLMode
+}
+
+// timestampBoundOf returns the timestamp bound set on ctx, or the read-only
+// staleness of the connection if there is none.
+func (c *spannerConn) timestampBoundOf(ctx context.Context) spanner.TimestampBound {
+	if tb, ok := timestampBoundFromContext(ctx); ok {
+		return tb
+	}
+	return c.staleness
 }
 
 func (c *spannerConn) execContextInNewRWTransaction(ctx context.Context, statement spanner.Statement) (int64, error) {
@@ -192,10 +364,12 @@ func (c *spannerConn) execContextInNewRWTransaction(ctx context.Context, stateme
 		rowsAffected = count
 		return err
 	}
-	_, err := c.client.ReadWriteTransaction(ctx, fn)
+	c.commitTimestamp = time.Time{}
+	commitTimestamp, err := c.client.ReadWriteTransaction(ctx, fn)
 	if err != nil {
 		return 0, err
 	}
+	c.commitTimestamp = commitTimestamp
 	return rowsAffected, nil
 }
 
@@ -209,28 +383,39 @@ func (c *spannerConn) query(ctx context.Context, query string, args []driver.Nam
 	}
 	defer c.finish()
 
+	if s, params := parseClientSideStatement(query); s != nil {
+		return c.queryClientSideStatement(ctx, s, params, args)
+	}
+	if c.batch != nil {
+		return nil, errors.New("cannot execute queries in a batch")
+	}
 	ss, err := prepareSpannerStmt(query, args)
 	if err != nil {
 		return nil, err
 	}
 
-	var it *spanner.RowIterator
+	var it rowIterator
 	if c.roTx != nil {
-		it = c.roTx.Query(ctx, ss)
+		it = spannerIterator{c.roTx.Query(ctx, ss)}
 	} else if c.rwTx != nil {
 		it = c.rwTx.Query(ctx, ss)
 	} else {
-		it = c.client.Single().Query(ctx, ss)
+		single := c.client.Single().WithTimestampBound(c.timestampBoundOf(ctx))
+		c.lastReadOnlyTx = single
+		it = spannerIterator{single.Query(ctx, ss)}
 	}
 
+	// Read the first row eagerly, so that query errors are returned here and
+	// the result set metadata is available to Columns even for empty results.
 	row, err := it.Next()
 	if err == iterator.Done {
-		return &spannerRows{it: it, done: true}, nil
+		return &spannerRows{conn: c, it: it, done: true}, nil
 	} else if err != nil {
-		return nil, err
+		it.Stop()
+		return nil, c.badConnError(err)
 	}
 
-	return &spannerRows{dirtyRow: row, it: it, done: false}, nil
+	return &spannerRows{conn: c, dirtyRow: row, it: it, done: false}, nil
 }
 
 func (c *spannerConn) prepare(query string) (*spannerStmt, error) {
@@ -239,7 +424,10 @@ func (c *spannerConn) prepare(query string) (*spannerStmt, error) {
 		return nil, driver.ErrBadConn
 	}
 
-	// TODO(jbd): Mention emails need to be escaped.
+	query, err := internal.RewritePositionalParams(query)
+	if err != nil {
+		return nil, err
+	}
 	args, err := internal.NamedValueParamNames(query, -1)
 	if err != nil {
 		return nil, err
@@ -247,10 +435,51 @@ func (c *spannerConn) prepare(query string) (*spannerStmt, error) {
 	return &spannerStmt{conn: c, query: query, numArgs: len(args)}, nil
 }
 
+// checkWritable returns an error if the connection or its current
+// transaction is read-only.
+func (c *spannerConn) checkWritable() error {
+	if c.roTx != nil {
+		return ErrWriteInReadOnlyTransaction
+	}
+	if c.readOnly {
+		return ErrWriteInReadOnlyConnection
+	}
+	return nil
+}
+
 func (c *spannerConn) inTransaction() bool {
 	return c.roTx != nil || c.rwTx != nil
 }
 
+// badConnError returns driver.ErrBadConn and closes the connection if err
+// shows that its session was deleted, so that database/sql discards the
+// connection and retries on another one. In a transaction the statement cannot
+// be retried, and a closed client is shared by the other connections, so in
+// these cases err is returned and the connection is discarded when it is
+// returned to the pool. Other errors are returned wrapped by wrapError.
+func (c *spannerConn) badConnError(err error) error {
+	if !isBadConnError(err) {
+		return wrapError(err)
+	}
+	if c.inTransaction() || !isSessionNotFoundError(err) {
+		return c.markBadConn(err)
+	}
+	errLog.Print(err)
+	c.cleanup()
+	return driver.ErrBadConn
+}
+
+// markBadConn marks the connection to be discarded when it is returned to the
+// pool if err shows that its session or client cannot be used anymore, and
+// returns err wrapped by wrapError. It is used where the failed operation
+// cannot be retried, e.g. when reading the next row of a query.
+func (c *spannerConn) markBadConn(err error) error {
+	if isBadConnError(err) {
+		c.bad.Set(true)
+	}
+	return wrapError(err)
+}
+
 // finish is called when the query has canceled.
 func (c *spannerConn) cancel(err error) {
 	c.canceled.Set(err)
@@ -288,7 +517,7 @@ func (c *spannerConn) watchCancel(ctx context.Context) error {
 	}
 	// When ctx is already cancelled, don't watch it.
 	if err := ctx.Err(); err != nil {
-		return err
+		return wrapError(err)
 	}
 	// When ctx is not cancellable, don't watch it.
 	if ctx.Done() == nil {
@@ -1,7 +1,14 @@
 package spannerdriver
 
 import (
+	"context"
 	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"cloud.google.com/go/spanner"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // static interface implementation checks of spannerConn
@@ -11,6 +18,123 @@ var (
 	_ driver.ConnPrepareContext = &spannerConn{}
 	_ driver.ExecerContext      = &spannerConn{}
 	_ driver.QueryerContext     = &spannerConn{}
+	_ driver.NamedValueChecker  = &spannerConn{}
+	_ SpannerConn               = &spannerConn{}
 	// _ driver.Pinger             = &spannerConn{}
 	// _ driver.SessionResetter    = &spannerConn{}
 )
+
+func TestMutationsOutsideReadWriteTransaction(t *testing.T) {
+	ctx := context.Background()
+	ms := []*spanner.Mutation{spanner.Insert("test", []string{"Id"}, []interface{}{"userId1"})}
+
+	c := newTestConn()
+	if err := c.BufferWrite(ms); err == nil {
+		t.Error("expected error for BufferWrite outside a transaction, got nil")
+	}
+
+	c.readOnly = true
+	if err := c.BufferWrite(ms); err != ErrWriteInReadOnlyConnection {
+		t.Errorf("expected ErrWriteInReadOnlyConnection, got %v", err)
+	}
+	if _, err := c.Apply(ctx, ms); err != ErrWriteInReadOnlyConnection {
+		t.Errorf("expected ErrWriteInReadOnlyConnection, got %v", err)
+	}
+
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Apply(ctx, ms); err != driver.ErrBadConn {
+		t.Errorf("expected driver.ErrBadConn, got %v", err)
+	}
+}
+
+func TestBadConnError(t *testing.T) {
+	ctx := context.Background()
+	sessionNotFound := spanner.ToSpannerError(status.Error(codes.NotFound, "Session not found: s"))
+
+	c := newTestConn()
+	if err := c.badConnError(spanner.ToSpannerError(status.Error(codes.NotFound, "Table not found: t"))); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if err := c.badConnError(sessionNotFound); err != driver.ErrBadConn {
+		t.Errorf("expected driver.ErrBadConn outside a transaction, got %v", err)
+	}
+	if c.IsValid() {
+		t.Error("expected the connection to be closed")
+	}
+
+	// In a transaction the error is returned, and the connection is discarded
+	// after the transaction.
+	c = newTestConn()
+	c.rwTx = &readWriteTransaction{}
+	if err := c.badConnError(sessionNotFound); err == driver.ErrBadConn || spanner.ErrCode(err) != codes.NotFound {
+		t.Errorf("expected the error in a transaction, got %v", err)
+	}
+	c.rwTx = nil
+	if c.IsValid() {
+		t.Error("expected the connection to be invalid")
+	}
+	if err := c.ResetSession(ctx); err != driver.ErrBadConn {
+		t.Errorf("expected driver.ErrBadConn from ResetSession, got %v", err)
+	}
+
+	// A closed client cannot be replaced by retrying on another connection, so
+	// the error is returned, and the connection is discarded.
+	c = newTestConn()
+	clientClosed := spanner.ToSpannerError(status.Error(codes.InvalidArgument, "invalid session pool"))
+	if err := c.badConnError(clientClosed); err == driver.ErrBadConn || spanner.ErrCode(err) != codes.InvalidArgument {
+		t.Errorf("expected the error of the closed client, got %v", err)
+	}
+	if c.IsValid() {
+		t.Error("expected the connection to be invalid")
+	}
+}
+
+func TestBadConnErrorAfterOperations(t *testing.T) {
+	ctx := context.Background()
+	sessionNotFound := spanner.ToSpannerError(status.Error(codes.NotFound, "Session not found: s"))
+
+	// The next row of a query cannot be retried, even outside a transaction.
+	c := newTestConn()
+	rows := &spannerRows{conn: c, it: &fakeRowIterator{err: sessionNotFound}}
+	if err := rows.Next(make([]driver.Value, 1)); err == driver.ErrBadConn || spanner.ErrCode(err) != codes.NotFound {
+		t.Errorf("expected the error of the query, got %v", err)
+	}
+	if c.IsValid() {
+		t.Error("expected the connection to be invalid after the query")
+	}
+
+	// Commit
+	c = newTestConn()
+	db := &fakeDatabase{commitErr: sessionNotFound}
+	fake, _ := db.begin(ctx)
+	c.rwTx = &readWriteTransaction{tx: fake}
+	tx := &rwTx{ctx: ctx, conn: c, close: func() { c.rwTx = nil }}
+	if err := tx.Commit(); err == driver.ErrBadConn || spanner.ErrCode(err) != codes.NotFound {
+		t.Errorf("expected the error of the commit, got %v", err)
+	}
+	if c.IsValid() {
+		t.Error("expected the connection to be invalid after the commit")
+	}
+
+	// Apply with a closed client
+	cfg, err := ParseDSN(emulatorDSN("d") + "&minSessions=0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := newClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Close()
+	c = newTestConn()
+	c.client = client
+	ms := []*spanner.Mutation{spanner.Insert("test", []string{"Id"}, []interface{}{"userId1"})}
+	if _, err := c.Apply(ctx, ms); err == driver.ErrBadConn || spanner.ErrCode(err) != codes.InvalidArgument {
+		t.Errorf("expected the error of the closed client, got %v", err)
+	}
+	if c.IsValid() {
+		t.Error("expected the connection to be invalid after Apply")
+	}
+}
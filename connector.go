@@ -3,32 +3,84 @@ package spannerdriver
 import (
 	"context"
 	"database/sql/driver"
+	"sync"
 
 	"cloud.google.com/go/spanner"
 	"google.golang.org/api/option"
 )
 
 type SpannerConnector struct {
-	client *spanner.Client
+	client   *spanner.Client
+	admin    *databaseAdmin
+	readOnly bool
+	// staleness is the default timestamp bound of the connections.
+	staleness spanner.TimestampBound
+	// retryAborts is the default of the connections for retrying aborted
+	// read-write transactions.
+	retryAborts bool
+
+	// closeClient releases the client and the admin client when the connector
+	// owns them.
+	closeClient func()
+	closeOnce   sync.Once
 }
 
+// NewConnectorWithClient returns database/sql/driver.Connector implementation
+// which uses the given client. DDL statements are executed by a database admin
+// client created with the default options, which respect SPANNER_EMULATOR_HOST.
+// Use NewConnectorWithClientOptions if the client was created with options,
+// such as credentials or an endpoint, which the admin client needs as well.
 func NewConnectorWithClient(client *spanner.Client) driver.Connector {
-	return &SpannerConnector{client: client}
+	return NewConnectorWithClientOptions(client)
+}
+
+// NewConnectorWithClientOptions returns database/sql/driver.Connector
+// implementation which uses the given client, and executes DDL statements by a
+// database admin client created with opts. Pass the options the client was
+// created with, so that DDL statements use the same credentials and endpoint.
+func NewConnectorWithClientOptions(client *spanner.Client, opts ...option.ClientOption) driver.Connector {
+	opts = append(append([]option.ClientOption{}, opts...), option.WithUserAgent(userAgent))
+	admin := newDatabaseAdmin(opts)
+	return &SpannerConnector{
+		client:      client,
+		admin:       admin,
+		staleness:   spanner.StrongRead(),
+		closeClient: func() { admin.close() },
+	}
 }
 
 // NewConnector returns database/sql/driver.Connector implementation for cloud spanner.
 func NewConnector(cfg *Config) (driver.Connector, error) {
-	opts := append(cfg.ClientOptions, option.WithUserAgent(userAgent))
-	client, err := spanner.NewClientWithConfig(
-		context.Background(),
-		cfg.Database,
-		cfg.ClientConfig,
-		opts...,
-	)
+	staleness, err := cfg.timestampBound()
 	if err != nil {
 		return nil, err
 	}
-	return &SpannerConnector{client: client}, nil
+	client, err := newClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	admin := newDatabaseAdmin(cfg.clientOptions())
+	closeClient := func() {
+		client.Close()
+		admin.close()
+	}
+	return &SpannerConnector{
+		client:      client,
+		admin:       admin,
+		readOnly:    cfg.ReadOnly,
+		staleness:   staleness,
+		retryAborts: cfg.RetryAbortsInternally,
+		closeClient: closeClient,
+	}, nil
+}
+
+func newClient(cfg *Config) (*spanner.Client, error) {
+	return spanner.NewClientWithConfig(
+		context.Background(),
+		cfg.Database,
+		cfg.clientConfig(),
+		cfg.clientOptions()...,
+	)
 }
 
 func (c *SpannerConnector) Client() *spanner.Client {
@@ -40,11 +92,35 @@ func (c *SpannerConnector) Driver() driver.Driver {
 	return &SpannerDriver{}
 }
 
+// Close implements io.Closer interface.
+// database/sql.DB.Close calls it (from Go 1.17) to close the client created by
+// the connector. A client passed to NewConnectorWithClient is not closed.
+func (c *SpannerConnector) Close() error {
+	c.closeOnce.Do(func() {
+		if c.closeClient != nil {
+			c.closeClient()
+		}
+	})
+	return nil
+}
+
 // Connect implements database/sql/driver.Connector interface
 func (c *SpannerConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return c.connect(ctx)
+}
+
+func (c *SpannerConnector) connect(ctx context.Context) (*spannerConn, error) {
 	conn := &spannerConn{
-		client:  c.client,
-		closech: make(chan struct{}),
+		client:   c.client,
+		admin:    c.admin,
+		readOnly: c.readOnly,
+		closech:  make(chan struct{}),
+
+		staleness:        c.staleness,
+		defaultStaleness: c.staleness,
+
+		retryAborts:        c.retryAborts,
+		defaultRetryAborts: c.retryAborts,
 	}
 	conn.startWatcher()
 	if err := conn.watchCancel(ctx); err != nil {
@@ -1,7 +1,120 @@
 package spannerdriver
 
 import (
+	"context"
+	"database/sql"
 	"database/sql/driver"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc/codes"
+)
+
+var (
+	_ driver.Connector = &SpannerConnector{}
+	_ io.Closer        = &SpannerConnector{}
 )
 
-var _ driver.Connector = &SpannerConnector{}
+// isClientClosed reports whether client has been closed, in which case its
+// session pool rejects every request without reaching the server.
+func isClientClosed(client *spanner.Client) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	_, err := client.Single().Query(ctx, spanner.NewStatement("SELECT 1")).Next()
+	return spanner.ErrCode(err) == codes.InvalidArgument && isBadConnError(err)
+}
+
+func TestDBCloseClosesClient(t *testing.T) {
+	cfg, err := ParseDSN(emulatorDSN("d"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	connector, err := NewConnector(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := connector.(*SpannerConnector).Client()
+
+	db := sql.OpenDB(connector)
+	if isClientClosed(client) {
+		t.Fatal("expected the client to be open before db.Close")
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !isClientClosed(client) {
+		t.Error("expected db.Close to close the client, but it leaked")
+	}
+}
+
+func TestDBCloseDoesNotCloseClientOfCaller(t *testing.T) {
+	cfg, err := ParseDSN(emulatorDSN("d"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := newClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	db := sql.OpenDB(NewConnectorWithClient(client))
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if isClientClosed(client) {
+		t.Error("expected the client passed to NewConnectorWithClient not to be closed")
+	}
+}
+
+func TestOpenConnectorCloseReleasesSharedClient(t *testing.T) {
+	cfg, err := ParseDSN(emulatorDSN("d"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := cfg.FormatDSN()
+
+	d := &SpannerDriver{}
+	connector1, err := d.OpenConnector(emulatorDSN("d"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	connector2, err := d.OpenConnector(emulatorDSN("d"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := connector1.(*SpannerConnector).Client()
+	if client != connector2.(*SpannerConnector).Client() {
+		t.Error("expected connectors of the same DSN to share the client")
+	}
+
+	// Closing twice must release the client only once.
+	connector1.(io.Closer).Close()
+	connector1.(io.Closer).Close()
+	if _, ok := clients.clients[key]; !ok || isClientClosed(client) {
+		t.Error("expected the client to stay open while connector2 uses it")
+	}
+	connector2.(io.Closer).Close()
+	if _, ok := clients.clients[key]; ok || !isClientClosed(client) {
+		t.Error("expected the client to be closed after all connectors are closed")
+	}
+}
+
+func TestNewConnectorWithClientOptions(t *testing.T) {
+	connector := NewConnectorWithClientOptions(nil, option.WithEndpoint(emulatorHost)).(*SpannerConnector)
+	defer connector.Close()
+	want := []option.ClientOption{option.WithEndpoint(emulatorHost), option.WithUserAgent(userAgent)}
+	if !reflect.DeepEqual(connector.admin.opts, want) {
+		t.Errorf("expected the admin client options %v, got %v", want, connector.admin.opts)
+	}
+
+	connector = NewConnectorWithClient(nil).(*SpannerConnector)
+	defer connector.Close()
+	if want := []option.ClientOption{option.WithUserAgent(userAgent)}; !reflect.DeepEqual(connector.admin.opts, want) {
+		t.Errorf("expected the admin client options %v, got %v", want, connector.admin.opts)
+	}
+}
@@ -0,0 +1,124 @@
+package spannerdriver
+
+import (
+	"context"
+	"database/sql/driver"
+	"sync"
+
+	adminapi "cloud.google.com/go/spanner/admin/database/apiv1"
+	"github.com/pkg/errors"
+	"google.golang.org/api/option"
+	adminpb "google.golang.org/genproto/googleapis/spanner/admin/database/v1"
+)
+
+// databaseAdmin creates a database admin client on first use, as only the
+// connections which execute DDL statements need one.
+type databaseAdmin struct {
+	opts []option.ClientOption
+
+	mu     sync.Mutex
+	client *adminapi.DatabaseAdminClient
+	closed bool
+}
+
+func newDatabaseAdmin(opts []option.ClientOption) *databaseAdmin {
+	return &databaseAdmin{opts: opts}
+}
+
+func (a *databaseAdmin) get(ctx context.Context) (*adminapi.DatabaseAdminClient, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.closed {
+		return nil, errors.New("database admin client is closed")
+	}
+	if a.client == nil {
+		client, err := adminapi.NewDatabaseAdminClient(ctx, a.opts...)
+		if err != nil {
+			return nil, err
+		}
+		a.client = client
+	}
+	return a.client, nil
+}
+
+func (a *databaseAdmin) close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.closed = true
+	if a.client == nil {
+		return nil
+	}
+	return a.client.Close()
+}
+
+func (c *spannerConn) execDDL(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if c.inTransaction() {
+		return nil, ErrDDLInTransaction
+	}
+	if c.batch != nil && c.batch.kind == batchDML {
+		return nil, errors.New("DDL statements cannot be executed in a DML batch")
+	}
+	if len(args) > 0 {
+		return nil, errors.New("DDL statements do not support parameters")
+	}
+	if c.batch != nil {
+		// The statement is executed by RUN BATCH.
+		c.batch.ddl = append(c.batch.ddl, query)
+		return &spannerResult{}, nil
+	}
+	if err := c.updateDDL(ctx, []string{query}); err != nil {
+		var batchErr *BatchDDLError
+		if errors.As(err, &batchErr) {
+			return nil, batchErr.Err
+		}
+		return nil, err
+	}
+	return &spannerResult{}, nil
+}
+
+// updateDDL executes the statements with a single UpdateDatabaseDdl request
+// and waits for the long-running operation to finish. If ctx is done first,
+// ctx.Err() is returned while the operation keeps running on the server.
+// If the operation fails, the error is a *BatchDDLError.
+func (c *spannerConn) updateDDL(ctx context.Context, statements []string) error {
+	client, err := c.admin.get(ctx)
+	if err != nil {
+		return wrapError(err)
+	}
+	op, err := client.UpdateDatabaseDdl(ctx, &adminpb.UpdateDatabaseDdlRequest{
+		Database:   c.client.DatabaseName(),
+		Statements: statements,
+	})
+	if err != nil {
+		return wrapError(err)
+	}
+	if err := op.Wait(ctx); err != nil {
+		return ddlError(statements, op, err)
+	}
+	return nil
+}
+
+// ddlOperation is the long-running operation of an UpdateDatabaseDdl request.
+type ddlOperation interface {
+	Done() bool
+	Metadata() (*adminpb.UpdateDatabaseDdlMetadata, error)
+}
+
+// ddlError returns the error of waiting for op. Only the error of a finished
+// operation is the error of a statement, which is returned as a
+// *BatchDDLError. Other errors, such as ctx being done or polling failures,
+// leave the statements applied so far unknown and are returned as they are.
+func ddlError(statements []string, op ddlOperation, err error) error {
+	if !op.Done() {
+		return wrapError(err)
+	}
+	// The metadata has a commit timestamp for every applied statement, and
+	// the operation stops at the statement which failed.
+	applied := 0
+	if md, mdErr := op.Metadata(); mdErr == nil && md != nil {
+		applied = len(md.GetCommitTimestamps())
+	}
+	return &BatchDDLError{Statements: statements, Applied: applied, Err: wrapError(err)}
+}
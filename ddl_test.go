@@ -0,0 +1,86 @@
+package spannerdriver
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"google.golang.org/api/option"
+	adminpb "google.golang.org/genproto/googleapis/spanner/admin/database/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestDatabaseAdmin(t *testing.T) {
+	ctx := context.Background()
+	admin := newDatabaseAdmin([]option.ClientOption{option.WithEndpoint(emulatorHost), option.WithoutAuthentication()})
+
+	client1, err := admin.get(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client2, err := admin.get(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client1 != client2 {
+		t.Error("expected the admin client to be created once")
+	}
+
+	if err := admin.close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := admin.get(ctx); err == nil {
+		t.Error("expected error after close, got nil")
+	}
+}
+
+type fakeDDLOperation struct {
+	done    bool
+	applied int
+}
+
+func (op *fakeDDLOperation) Done() bool {
+	return op.done
+}
+
+func (op *fakeDDLOperation) Metadata() (*adminpb.UpdateDatabaseDdlMetadata, error) {
+	md := &adminpb.UpdateDatabaseDdlMetadata{}
+	for i := 0; i < op.applied; i++ {
+		md.CommitTimestamps = append(md.CommitTimestamps, timestamppb.Now())
+	}
+	return md, nil
+}
+
+func TestDDLError(t *testing.T) {
+	statements := []string{
+		"CREATE TABLE A (Id INT64) PRIMARY KEY (Id)",
+		"CREATE TABLE Users (Id INT64) PRIMARY KEY (Id)",
+	}
+
+	// The operation reports the statement which failed.
+	cause := status.Error(codes.FailedPrecondition, "Duplicate name in schema: Users")
+	err := ddlError(statements, &fakeDDLOperation{done: true, applied: 1}, cause)
+	var batchErr *BatchDDLError
+	if !errors.As(err, &batchErr) {
+		t.Fatalf("expected BatchDDLError, got %v", err)
+	}
+	if batchErr.Applied != 1 || batchErr.FailedStatement() != statements[1] {
+		t.Errorf("expected the second statement to fail, got %d applied, %q failed", batchErr.Applied, batchErr.FailedStatement())
+	}
+	if !errors.Is(err, cause) {
+		t.Error("expected BatchDDLError to wrap the error of the failed statement")
+	}
+
+	// Waiting for the operation failed, so no statement is to blame.
+	for _, cause := range []error{context.Canceled, status.Error(codes.Unavailable, "connection reset")} {
+		err := ddlError(statements, &fakeDDLOperation{applied: 1}, cause)
+		if errors.As(err, &batchErr) {
+			t.Errorf("%v: expected no BatchDDLError, got %v", cause, err)
+		}
+		if !errors.Is(err, cause) {
+			t.Errorf("%v: expected the error to be returned, got %v", cause, err)
+		}
+	}
+}
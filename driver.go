@@ -4,6 +4,9 @@ import (
 	"context"
 	"database/sql"
 	"database/sql/driver"
+	"sync"
+
+	"cloud.google.com/go/spanner"
 )
 
 const userAgent = "go-sql-driver/spanner v0.0.1"
@@ -15,17 +18,146 @@ func init() {
 type SpannerDriver struct{}
 
 // Open implements database/sql/driver.Driver interface
-func (d *SpannerDriver) Open(database string) (driver.Conn, error) {
-	cfg := &Config{Database: database}
-	connector, err := NewConnector(cfg)
+// See ParseDSN for the format of dsn.
+func (d *SpannerDriver) Open(dsn string) (driver.Conn, error) {
+	cfg, err := ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	staleness, err := cfg.timestampBound()
+	if err != nil {
+		return nil, err
+	}
+	c, release, err := clients.acquire(cfg)
+	if err != nil {
+		return nil, err
+	}
+	connector := &SpannerConnector{
+		client:      c.client,
+		admin:       c.admin,
+		readOnly:    cfg.ReadOnly,
+		staleness:   staleness,
+		retryAborts: cfg.RetryAbortsInternally,
+	}
+	conn, err := connector.connect(context.Background())
 	if err != nil {
+		release()
 		return nil, err
 	}
-	return connector.Connect(context.Background())
+	conn.release = release
+	return conn, nil
 }
 
 // OpenConnector implements database/sql/driver.DriverContext interface
-func (d *SpannerDriver) OpenConnector(database string) (driver.Connector, error) {
-	cfg := NewConfig(database)
-	return NewConnector(cfg)
+// See ParseDSN for the format of dsn.
+func (d *SpannerDriver) OpenConnector(dsn string) (driver.Connector, error) {
+	cfg, err := ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	staleness, err := cfg.timestampBound()
+	if err != nil {
+		return nil, err
+	}
+	c, release, err := clients.acquire(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &SpannerConnector{
+		client:      c.client,
+		admin:       c.admin,
+		readOnly:    cfg.ReadOnly,
+		staleness:   staleness,
+		retryAborts: cfg.RetryAbortsInternally,
+		closeClient: release,
+	}, nil
+}
+
+// clientCache shares a spanner.Client and a database admin client between all
+// connections and connectors opened by the driver for the same DSN, so that
+// they share the session pool and the gRPC channels of the client.
+type clientCache struct {
+	mu      sync.Mutex
+	clients map[string]*cachedClient
+	// newClient creates the client of a DSN. It is called without holding mu,
+	// as it can take long to connect.
+	newClient func(cfg *Config) (*spanner.Client, error)
+}
+
+type cachedClient struct {
+	client *spanner.Client
+	admin  *databaseAdmin
+	refs   int
+	// ready is closed when client has been created, or err is set if it
+	// could not be created.
+	ready chan struct{}
+	err   error
+}
+
+var clients = newClientCache()
+
+func newClientCache() *clientCache {
+	return &clientCache{clients: make(map[string]*cachedClient), newClient: newClient}
+}
+
+// acquire returns the clients for cfg, creating them on first use. The returned
+// release func must be called when the clients are no longer used; they are
+// closed when the last reference to them is released. Concurrent calls for
+// the same DSN wait for the client created by the first one.
+func (cc *clientCache) acquire(cfg *Config) (*cachedClient, func(), error) {
+	key := cfg.FormatDSN()
+
+	cc.mu.Lock()
+	c, ok := cc.clients[key]
+	if !ok {
+		c = &cachedClient{ready: make(chan struct{})}
+		cc.clients[key] = c
+	}
+	c.refs++
+	cc.mu.Unlock()
+
+	if ok {
+		<-c.ready
+	} else {
+		c.client, c.err = cc.newClient(cfg)
+		if c.err == nil {
+			c.admin = newDatabaseAdmin(cfg.clientOptions())
+		} else {
+			// The next call for the DSN tries again.
+			cc.mu.Lock()
+			delete(cc.clients, key)
+			cc.mu.Unlock()
+		}
+		close(c.ready)
+	}
+	if c.err != nil {
+		cc.release(key, c)
+		return nil, nil, c.err
+	}
+
+	var once sync.Once
+	release := func() {
+		once.Do(func() { cc.release(key, c) })
+	}
+	return c, release, nil
+}
+
+// release drops a reference to c, and closes its clients after removing it
+// from the cache when it was the last one.
+func (cc *clientCache) release(key string, c *cachedClient) {
+	cc.mu.Lock()
+	c.refs--
+	if c.refs > 0 {
+		cc.mu.Unlock()
+		return
+	}
+	if cc.clients[key] == c {
+		delete(cc.clients, key)
+	}
+	cc.mu.Unlock()
+
+	if c.client != nil {
+		c.client.Close()
+		c.admin.close()
+	}
 }
@@ -2,8 +2,13 @@ package spannerdriver
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math/big"
+	"net/url"
 	"os"
+	"reflect"
 	"testing"
 	"time"
 
@@ -13,6 +18,7 @@ import (
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
+	"cloud.google.com/go/civil"
 	"cloud.google.com/go/spanner"
 	adminapi "cloud.google.com/go/spanner/admin/database/apiv1"
 	instanceapi "cloud.google.com/go/spanner/admin/instance/apiv1"
@@ -26,10 +32,11 @@ var (
 )
 
 var (
-	dsn      string
-	project  string
-	instance string
-	database string
+	dsn          string
+	project      string
+	instance     string
+	database     string
+	emulatorHost string
 )
 
 func init() {
@@ -39,8 +46,8 @@ func init() {
 		}
 		return defaultValue
 	}
-	emulator_host := env("SPANNER_EMULATOR_HOST", "")
-	if emulator_host == "" {
+	emulatorHost = env("SPANNER_EMULATOR_HOST", "")
+	if emulatorHost == "" {
 		panic("cannot setup spanner because env 'SPANNER_EMULATOR_HOST' is not set")
 	}
 
@@ -50,6 +57,12 @@ func init() {
 	dsn = fmt.Sprintf("projects/%s/instances/%s/databases/%s", project, instance, database)
 }
 
+// emulatorDSN returns a DSN of db on the emulator of SPANNER_EMULATOR_HOST, for
+// the tests which create their own clients.
+func emulatorDSN(db string) string {
+	return fmt.Sprintf("projects/p/instances/i/databases/%s?emulatorHost=%s", db, url.QueryEscape(emulatorHost))
+}
+
 type DBTest struct {
 	*testing.T
 	db     *sql.DB
@@ -424,3 +437,913 @@ func TestContextCancelBegin(t *testing.T) {
 		}
 	})
 }
+
+func TestArrayTypes(t *testing.T) {
+	runTests(t, dsn, func(dbt *DBTest) {
+		var (
+			ints      NullInt64Array
+			strs      StringArray
+			nullArray Int64Array
+		)
+		row := dbt.db.QueryRow("SELECT [1, NULL, 3], ['a', 'b'], CAST(NULL AS ARRAY<INT64>)")
+		if err := row.Scan(&ints, &strs, &nullArray); err != nil {
+			dbt.Fatal(err)
+		}
+
+		if len(ints) != 3 || ints[0].Int64 != 1 || ints[1].Valid || ints[2].Int64 != 3 {
+			dbt.Errorf("unexpected ARRAY<INT64> value: %v", ints)
+		}
+		if len(strs) != 2 || strs[0] != "a" || strs[1] != "b" {
+			dbt.Errorf("unexpected ARRAY<STRING> value: %v", strs)
+		}
+		if nullArray != nil {
+			dbt.Errorf("expected nil for NULL array, got %v", nullArray)
+		}
+	})
+}
+
+func TestNumericType(t *testing.T) {
+	runTests(t, dsn, func(dbt *DBTest) {
+		var (
+			s string
+			n NullNumeric
+			m NullNumeric
+		)
+		want := big.NewRat(123456789, 1000)
+		row := dbt.db.QueryRow("SELECT @n, @n, CAST(NULL AS NUMERIC)", want)
+		if err := row.Scan(&s, &n, &m); err != nil {
+			dbt.Fatal(err)
+		}
+		if s != "123456.789000000" {
+			dbt.Errorf("expected 123456.789000000, got %s", s)
+		}
+		if !n.Valid || n.Numeric.Cmp(want) != 0 {
+			dbt.Errorf("expected %v, got %v", want, n)
+		}
+		if m.Valid {
+			dbt.Errorf("expected NULL, got %v", m)
+		}
+
+		row = dbt.db.QueryRow("SELECT @n", Numeric("0.1"))
+		if err := row.Scan(&s); err != nil {
+			dbt.Fatal(err)
+		}
+		if s != "0.100000000" {
+			dbt.Errorf("expected 0.100000000, got %s", s)
+		}
+	})
+}
+
+func TestJSONType(t *testing.T) {
+	runTests(t, dsn, func(dbt *DBTest) {
+		var (
+			raw  json.RawMessage
+			v    struct{ Name string }
+			null NullJSON
+		)
+		row := dbt.db.QueryRow("SELECT @j, @j, CAST(NULL AS JSON)", json.RawMessage(`{"Name":"foo"}`))
+		if err := row.Scan(&raw, &JSON{Value: &v}, &null); err != nil {
+			dbt.Fatal(err)
+		}
+		if string(raw) != `{"Name":"foo"}` {
+			dbt.Errorf(`expected {"Name":"foo"}, got %s`, raw)
+		}
+		if v.Name != "foo" {
+			dbt.Errorf("expected foo, got %s", v.Name)
+		}
+		if null.Valid {
+			dbt.Errorf("expected NULL, got %v", null)
+		}
+	})
+}
+
+func TestNullValues(t *testing.T) {
+	runTests(t, dsn, func(dbt *DBTest) {
+		var (
+			b  sql.NullBool
+			i  sql.NullInt64
+			f  sql.NullFloat64
+			s  sql.NullString
+			ts sql.NullTime
+			d  sql.NullTime
+			bs []byte
+		)
+		row := dbt.db.QueryRow(`SELECT CAST(NULL AS BOOL), CAST(NULL AS INT64), CAST(NULL AS FLOAT64),
+			CAST(NULL AS STRING), CAST(NULL AS TIMESTAMP), CAST(NULL AS DATE), CAST(NULL AS BYTES)`)
+		if err := row.Scan(&b, &i, &f, &s, &ts, &d, &bs); err != nil {
+			dbt.Fatal(err)
+		}
+		if b.Valid || i.Valid || f.Valid || s.Valid || ts.Valid || d.Valid || bs != nil {
+			dbt.Errorf("expected all values to be NULL, got %v %v %v %v %v %v %v", b, i, f, s, ts, d, bs)
+		}
+
+		dbt.mustExec("INSERT INTO test (Id) VALUES (\"userId1\")")
+		if err := dbt.db.QueryRow("SELECT Value FROM test WHERE Id = \"userId1\"").Scan(&b); err != nil {
+			dbt.Fatal(err)
+		}
+		if b.Valid {
+			dbt.Errorf("expected NULL, got %v", b)
+		}
+	})
+}
+
+func TestStructType(t *testing.T) {
+	runTests(t, dsn, func(dbt *DBTest) {
+		type key struct {
+			ID string
+		}
+		dbt.mustExec("INSERT INTO test (Id, Value) VALUES (\"userId1\", true), (\"userId2\", false)")
+
+		rows := dbt.mustQuery("SELECT Id FROM test WHERE Id IN UNNEST(ARRAY(SELECT r.ID FROM UNNEST(@rows) AS r)) ORDER BY Id",
+			[]key{{ID: "userId1"}, {ID: "userId2"}, {ID: "userId3"}})
+		var ids []string
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				dbt.Fatal(err)
+			}
+			ids = append(ids, id)
+		}
+		rows.Close()
+		if len(ids) != 2 || ids[0] != "userId1" || ids[1] != "userId2" {
+			dbt.Errorf("unexpected ids: %v", ids)
+		}
+
+		type record struct {
+			ID    string `spanner:"Id"`
+			Value bool
+		}
+		var records []record
+		row := dbt.db.QueryRow("SELECT ARRAY(SELECT AS STRUCT Id, Value FROM test ORDER BY Id)")
+		if err := row.Scan(&Struct{Value: &records}); err != nil {
+			dbt.Fatal(err)
+		}
+		if len(records) != 2 || records[0] != (record{"userId1", true}) || records[1] != (record{"userId2", false}) {
+			dbt.Errorf("unexpected records: %v", records)
+		}
+	})
+}
+
+func TestColumnTypes(t *testing.T) {
+	runTests(t, dsn, func(dbt *DBTest) {
+		dbt.mustExec("INSERT INTO test (Id, Value) VALUES (\"userId1\", true)")
+
+		rows := dbt.mustQuery("SELECT Id, Value, [Id], CAST(1 AS NUMERIC) FROM test")
+		defer rows.Close()
+		types, err := rows.ColumnTypes()
+		if err != nil {
+			dbt.Fatal(err)
+		}
+		want := []string{"STRING", "BOOL", "ARRAY<STRING>", "NUMERIC"}
+		if len(types) != len(want) {
+			dbt.Fatalf("expected %d columns, got %d", len(want), len(types))
+		}
+		for i, ct := range types {
+			if ct.DatabaseTypeName() != want[i] {
+				dbt.Errorf("column %d: expected %s, got %s", i, want[i], ct.DatabaseTypeName())
+			}
+		}
+		if types[0].ScanType() != reflect.TypeOf(sql.NullString{}) {
+			dbt.Errorf("expected sql.NullString, got %s", types[0].ScanType())
+		}
+	})
+}
+
+func TestColumnsOfEmptyResult(t *testing.T) {
+	runTests(t, dsn, func(dbt *DBTest) {
+		rows := dbt.mustQuery("SELECT Id, Value FROM test")
+		defer rows.Close()
+		cols, err := rows.Columns()
+		if err != nil {
+			dbt.Fatal(err)
+		}
+		if want := []string{"Id", "Value"}; !reflect.DeepEqual(cols, want) {
+			dbt.Errorf("expected %v, got %v", want, cols)
+		}
+		if rows.Next() {
+			dbt.Error("unexpected data in empty table")
+		}
+	})
+}
+
+func TestReadOnlyDSN(t *testing.T) {
+	runTests(t, dsn, func(dbt *DBTest) {
+		db, err := sql.Open("spanner", dsn+"?readOnly=true")
+		if err != nil {
+			dbt.Fatal(err)
+		}
+		defer db.Close()
+
+		if _, err := db.Exec("INSERT INTO test (Id, Value) VALUES (\"userId1\", true)"); err != ErrWriteInReadOnlyConnection {
+			dbt.Errorf("expected ErrWriteInReadOnlyConnection, got %v", err)
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			dbt.Fatal(err)
+		}
+		if _, err := tx.Exec("INSERT INTO test (Id, Value) VALUES (\"userId1\", true)"); err != ErrWriteInReadOnlyTransaction {
+			dbt.Errorf("expected ErrWriteInReadOnlyTransaction, got %v", err)
+		}
+		var count int64
+		if err := tx.QueryRow("SELECT COUNT(*) FROM test").Scan(&count); err != nil {
+			dbt.Fatal(err)
+		}
+		if err := tx.Commit(); err != nil {
+			dbt.Fatal(err)
+		}
+	})
+}
+
+func TestClientCache(t *testing.T) {
+	cc := newClientCache()
+	cfg1, err := ParseDSN(emulatorDSN("d") + "&numChannels=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Same parameters in a different order must share the client.
+	cfg2, err := ParseDSN("projects/p/instances/i/databases/d?numChannels=1&emulatorHost=" + emulatorHost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg3, err := ParseDSN(emulatorDSN("other") + "&numChannels=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client1, release1, err := cc.acquire(cfg1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client2, release2, err := cc.acquire(cfg2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client3, release3, err := cc.acquire(cfg3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client1.client != client2.client || client1.admin != client2.admin {
+		t.Error("expected clients of the same DSN to be shared")
+	}
+	if client1.client == client3.client {
+		t.Error("expected clients of different DSNs not to be shared")
+	}
+
+	release1()
+	release1() // releasing twice must not drop the reference of client2
+	if len(cc.clients) != 2 {
+		t.Errorf("expected 2 cached clients, got %d", len(cc.clients))
+	}
+	release2()
+	release3()
+	if len(cc.clients) != 0 {
+		t.Errorf("expected no cached clients, got %d", len(cc.clients))
+	}
+}
+
+func TestClientCacheCreatesClientsWithoutLock(t *testing.T) {
+	cc := newClientCache()
+	slow, err := ParseDSN(emulatorDSN("slow") + "&numChannels=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fast, err := ParseDSN(emulatorDSN("fast") + "&numChannels=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unblock := make(chan struct{})
+	cc.newClient = func(cfg *Config) (*spanner.Client, error) {
+		if cfg.Database == slow.Database {
+			<-unblock
+		}
+		return newClient(cfg)
+	}
+
+	type acquired struct {
+		c       *cachedClient
+		release func()
+		err     error
+	}
+	slowResults := make(chan acquired, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			c, release, err := cc.acquire(slow)
+			slowResults <- acquired{c, release, err}
+		}()
+	}
+
+	// The slow DSN does not block the clients of other DSNs.
+	done := make(chan acquired)
+	go func() {
+		c, release, err := cc.acquire(fast)
+		done <- acquired{c, release, err}
+	}()
+	select {
+	case a := <-done:
+		if a.err != nil {
+			t.Fatal(a.err)
+		}
+		a.release()
+	case <-time.After(5 * time.Second):
+		t.Fatal("acquire of another DSN blocked while a client was being created")
+	}
+
+	close(unblock)
+	a1, a2 := <-slowResults, <-slowResults
+	if a1.err != nil || a2.err != nil {
+		t.Fatal(a1.err, a2.err)
+	}
+	if a1.c.client != a2.c.client {
+		t.Error("expected concurrent calls for the same DSN to share the client")
+	}
+	a1.release()
+	a2.release()
+	if len(cc.clients) != 0 {
+		t.Errorf("expected no cached clients, got %d", len(cc.clients))
+	}
+
+	// A failed creation is not cached.
+	cc.newClient = func(cfg *Config) (*spanner.Client, error) {
+		return nil, errors.New("failed")
+	}
+	if _, _, err := cc.acquire(slow); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if len(cc.clients) != 0 {
+		t.Errorf("expected no cached clients, got %d", len(cc.clients))
+	}
+}
+
+func TestPreparedExec(t *testing.T) {
+	runTests(t, dsn, func(dbt *DBTest) {
+		dbt.mustExec("INSERT INTO test (Id, Value) VALUES (\"userId1\", true), (\"userId2\", true)")
+
+		stmt, err := dbt.db.Prepare("UPDATE test SET Value = @value WHERE Id = @id")
+		if err != nil {
+			dbt.Fatal(err)
+		}
+		defer stmt.Close()
+
+		res, err := stmt.Exec(false, "userId1")
+		if err != nil {
+			dbt.Fatal(err)
+		}
+		if count, _ := res.RowsAffected(); count != 1 {
+			dbt.Errorf("expected 1 affected row, got %d", count)
+		}
+
+		// Prepared statements must run in the current transaction.
+		tx, err := dbt.db.Begin()
+		if err != nil {
+			dbt.Fatal(err)
+		}
+		if _, err := tx.Stmt(stmt).Exec(false, "userId2"); err != nil {
+			dbt.Fatal(err)
+		}
+		if err := tx.Rollback(); err != nil {
+			dbt.Fatal(err)
+		}
+
+		var count int64
+		if err := dbt.db.QueryRow("SELECT COUNT(*) FROM test WHERE Value = false").Scan(&count); err != nil {
+			dbt.Fatal(err)
+		}
+		if count != 1 {
+			dbt.Errorf("expected 1 row to be updated, got %d", count)
+		}
+	})
+}
+
+func TestParameterTypes(t *testing.T) {
+	runTests(t, dsn, func(dbt *DBTest) {
+		var (
+			n     int64
+			date  time.Time
+			s     sql.NullString
+			count int64
+		)
+		row := dbt.db.QueryRow("SELECT ARRAY_LENGTH(@list), @date, @s, @n",
+			[]string{"a", "b"}, civil.Date{Year: 2021, Month: 11, Day: 4}, sql.NullString{}, uint8(3))
+		if err := row.Scan(&count, &date, &s, &n); err != nil {
+			dbt.Fatal(err)
+		}
+		if count != 2 {
+			dbt.Errorf("expected 2, got %d", count)
+		}
+		if date.Year() != 2021 || date.Month() != 11 || date.Day() != 4 {
+			dbt.Errorf("expected 2021-11-04, got %s", date)
+		}
+		if s.Valid {
+			dbt.Errorf("expected NULL, got %v", s)
+		}
+		if n != 3 {
+			dbt.Errorf("expected 3, got %d", n)
+		}
+
+		if _, err := dbt.db.Query("SELECT @m", map[string]string{}); err == nil {
+			dbt.Error("expected error for unsupported parameter type, got nil")
+		}
+	})
+}
+
+func TestPositionalParameters(t *testing.T) {
+	runTests(t, dsn, func(dbt *DBTest) {
+		dbt.mustExec("INSERT INTO test (Id, Value) VALUES (?, ?), (?, ?)", "userId1", true, "userId2", false)
+
+		stmt, err := dbt.db.Prepare("SELECT Id FROM test WHERE Value = ? AND Id != '?'")
+		if err != nil {
+			dbt.Fatal(err)
+		}
+		defer stmt.Close()
+
+		var id string
+		if err := stmt.QueryRow(true).Scan(&id); err != nil {
+			dbt.Fatal(err)
+		}
+		if id != "userId1" {
+			dbt.Errorf("expected userId1, got %s", id)
+		}
+
+		if _, err := dbt.db.Query("SELECT Id FROM test WHERE Value = ? AND Id = @id", true, "userId1"); err == nil {
+			dbt.Error("expected error for mixed parameters, got nil")
+		}
+	})
+}
+
+func TestDDL(t *testing.T) {
+	runTests(t, dsn, func(dbt *DBTest) {
+		ctx := context.Background()
+		if _, err := dbt.db.ExecContext(ctx, "CREATE TABLE ddl_test (Id INT64 NOT NULL, Name STRING(MAX)) PRIMARY KEY (Id)"); err != nil {
+			dbt.Fatal(err)
+		}
+		dbt.mustExec("INSERT INTO ddl_test (Id, Name) VALUES (1, 'a')")
+		dbt.mustExec("-- add an index\nCREATE INDEX ddl_test_by_name ON ddl_test (Name)")
+
+		tx, err := dbt.db.Begin()
+		if err != nil {
+			dbt.Fatal(err)
+		}
+		if _, err := tx.Exec("DROP INDEX ddl_test_by_name"); err != ErrDDLInTransaction {
+			dbt.Errorf("expected ErrDDLInTransaction, got %v", err)
+		}
+		if err := tx.Rollback(); err != nil {
+			dbt.Fatal(err)
+		}
+
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+		if _, err := dbt.db.ExecContext(canceled, "DROP INDEX ddl_test_by_name"); err == nil {
+			dbt.Error("expected error for canceled context, got nil")
+		}
+
+		dbt.mustExec("DROP INDEX ddl_test_by_name")
+		dbt.mustExec("DROP TABLE ddl_test")
+		if _, err := dbt.db.Query("SELECT * FROM ddl_test"); err == nil {
+			dbt.Error("expected error for dropped table, got nil")
+		}
+	})
+}
+
+func TestDDLBatch(t *testing.T) {
+	runTests(t, dsn, func(dbt *DBTest) {
+		ctx := context.Background()
+		conn, err := dbt.db.Conn(ctx)
+		if err != nil {
+			dbt.Fatal(err)
+		}
+		defer conn.Close()
+
+		for _, query := range []string{
+			"START BATCH DDL",
+			"CREATE TABLE batch_test (Id INT64 NOT NULL, Name STRING(MAX)) PRIMARY KEY (Id)",
+			"CREATE INDEX batch_test_by_name ON batch_test (Name)",
+			"RUN BATCH",
+		} {
+			if _, err := conn.ExecContext(ctx, query); err != nil {
+				dbt.Fatalf("error on %s: %v", query, err)
+			}
+		}
+		dbt.mustExec("INSERT INTO batch_test (Id, Name) VALUES (1, 'a')")
+
+		// The statements before the failed one are applied.
+		for _, query := range []string{
+			"START BATCH DDL",
+			"DROP INDEX batch_test_by_name",
+			"DROP INDEX batch_test_by_name",
+			"DROP TABLE batch_test",
+		} {
+			if _, err := conn.ExecContext(ctx, query); err != nil {
+				dbt.Fatalf("error on %s: %v", query, err)
+			}
+		}
+		_, err = conn.ExecContext(ctx, "RUN BATCH")
+		var batchErr *BatchDDLError
+		if !errors.As(err, &batchErr) {
+			dbt.Fatalf("expected BatchDDLError, got %v", err)
+		}
+		if batchErr.Applied != 1 {
+			dbt.Errorf("expected 1 applied statement, got %d", batchErr.Applied)
+		}
+
+		// Aborted statements are not executed.
+		for _, query := range []string{"START BATCH DDL", "DROP TABLE batch_test", "ABORT BATCH"} {
+			if _, err := conn.ExecContext(ctx, query); err != nil {
+				dbt.Fatalf("error on %s: %v", query, err)
+			}
+		}
+		dbt.mustExec("DROP TABLE batch_test")
+	})
+}
+
+func TestDMLBatch(t *testing.T) {
+	runTests(t, dsn, func(dbt *DBTest) {
+		ctx := context.Background()
+		conn, err := dbt.db.Conn(ctx)
+		if err != nil {
+			dbt.Fatal(err)
+		}
+		defer conn.Close()
+
+		for _, query := range []string{"START BATCH DML", "INSERT INTO test (Id, Value) VALUES ('userId1', true)"} {
+			if _, err := conn.ExecContext(ctx, query); err != nil {
+				dbt.Fatalf("error on %s: %v", query, err)
+			}
+		}
+		if _, err := conn.ExecContext(ctx, "INSERT INTO test (Id, Value) VALUES (@id, false)", "userId2"); err != nil {
+			dbt.Fatal(err)
+		}
+		res, err := conn.ExecContext(ctx, "RUN BATCH")
+		if err != nil {
+			dbt.Fatal(err)
+		}
+		if n, _ := res.RowsAffected(); n != 2 {
+			dbt.Errorf("expected 2 rows affected, got %d", n)
+		}
+
+		// BatchDML in a transaction.
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			dbt.Fatal(err)
+		}
+		result, err := BatchDML(ctx, conn, []spanner.Statement{
+			spanner.NewStatement("UPDATE test SET Value = false WHERE Value = true"),
+			spanner.NewStatement("DELETE FROM test WHERE Value = false"),
+		})
+		if err != nil {
+			dbt.Fatal(err)
+		}
+		if err := tx.Commit(); err != nil {
+			dbt.Fatal(err)
+		}
+		counts, err := result.BatchRowsAffected()
+		if err != nil {
+			dbt.Fatal(err)
+		}
+		if !reflect.DeepEqual(counts, []int64{1, 2}) {
+			dbt.Errorf("expected row counts [1 2], got %v", counts)
+		}
+
+		var count int64
+		if err := conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM test").Scan(&count); err != nil {
+			dbt.Fatal(err)
+		}
+		if count != 0 {
+			dbt.Errorf("expected no rows, got %d", count)
+		}
+	})
+}
+
+func TestPartitionedDML(t *testing.T) {
+	runTests(t, dsn, func(dbt *DBTest) {
+		ctx := context.Background()
+		dbt.mustExec("INSERT INTO test (Id, Value) VALUES ('userId1', true), ('userId2', true), ('userId3', false)")
+
+		res, err := dbt.db.ExecContext(WithAutocommitDMLMode(ctx, PartitionedNonAtomic), "UPDATE test SET Value = false WHERE Value = true")
+		if err != nil {
+			dbt.Fatal(err)
+		}
+		if n, _ := res.RowsAffected(); n != 2 {
+			dbt.Errorf("expected 2 rows affected, got %d", n)
+		}
+
+		conn, err := dbt.db.Conn(ctx)
+		if err != nil {
+			dbt.Fatal(err)
+		}
+		defer conn.Close()
+		if _, err := conn.ExecContext(ctx, "SET AUTOCOMMIT_DML_MODE = 'PARTITIONED_NON_ATOMIC'"); err != nil {
+			dbt.Fatal(err)
+		}
+		res, err = conn.ExecContext(ctx, "DELETE FROM test WHERE Value = false")
+		if err != nil {
+			dbt.Fatal(err)
+		}
+		if n, _ := res.RowsAffected(); n != 3 {
+			dbt.Errorf("expected 3 rows affected, got %d", n)
+		}
+
+		// Partitioned DML cannot be executed in a transaction, so the mode
+		// does not apply to transactions.
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			dbt.Fatal(err)
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT INTO test (Id, Value) VALUES ('userId4', true)"); err != nil {
+			dbt.Fatal(err)
+		}
+		if err := tx.Rollback(); err != nil {
+			dbt.Fatal(err)
+		}
+	})
+}
+
+func TestMutations(t *testing.T) {
+	runTests(t, dsn, func(dbt *DBTest) {
+		ctx := context.Background()
+		conn, err := dbt.db.Conn(ctx)
+		if err != nil {
+			dbt.Fatal(err)
+		}
+		defer conn.Close()
+
+		var commitTimestamp time.Time
+		if err := conn.Raw(func(driverConn interface{}) error {
+			commitTimestamp, err = driverConn.(SpannerConn).Apply(ctx, []*spanner.Mutation{
+				spanner.Insert("test", []string{"Id", "Value"}, []interface{}{"userId1", true}),
+			})
+			return err
+		}); err != nil {
+			dbt.Fatal(err)
+		}
+		if commitTimestamp.IsZero() {
+			dbt.Error("expected a commit timestamp")
+		}
+
+		// Mutations and DML in one transaction.
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			dbt.Fatal(err)
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE test SET Value = false WHERE Id = 'userId1'"); err != nil {
+			dbt.Fatal(err)
+		}
+		if err := conn.Raw(func(driverConn interface{}) error {
+			return driverConn.(SpannerConn).BufferWrite([]*spanner.Mutation{
+				spanner.Insert("test", []string{"Id", "Value"}, []interface{}{"userId2", true}),
+			})
+		}); err != nil {
+			dbt.Fatal(err)
+		}
+		if err := tx.Commit(); err != nil {
+			dbt.Fatal(err)
+		}
+
+		rows := dbt.mustQuery("SELECT Id, Value FROM test ORDER BY Id")
+		defer rows.Close()
+		want := map[string]bool{"userId1": false, "userId2": true}
+		for rows.Next() {
+			var id string
+			var value bool
+			if err := rows.Scan(&id, &value); err != nil {
+				dbt.Fatal(err)
+			}
+			if w, ok := want[id]; !ok || w != value {
+				dbt.Errorf("unexpected row (%s, %v)", id, value)
+			}
+			delete(want, id)
+		}
+		if len(want) > 0 {
+			dbt.Errorf("missing rows: %v", want)
+		}
+	})
+}
+
+func TestStaleReads(t *testing.T) {
+	runTests(t, dsn, func(dbt *DBTest) {
+		ctx := context.Background()
+		conn, err := dbt.db.Conn(ctx)
+		if err != nil {
+			dbt.Fatal(err)
+		}
+		defer conn.Close()
+
+		var commitTimestamp time.Time
+		if err := conn.Raw(func(driverConn interface{}) error {
+			commitTimestamp, err = driverConn.(SpannerConn).Apply(ctx, []*spanner.Mutation{
+				spanner.Insert("test", []string{"Id", "Value"}, []interface{}{"userId1", true}),
+			})
+			return err
+		}); err != nil {
+			dbt.Fatal(err)
+		}
+		dbt.mustExec("INSERT INTO test (Id, Value) VALUES ('userId2', true)")
+
+		count := func(ctx context.Context, q interface {
+			QueryRowContext(context.Context, string, ...interface{}) *sql.Row
+		}) int64 {
+			var n int64
+			if err := q.QueryRowContext(ctx, "SELECT COUNT(*) FROM test").Scan(&n); err != nil {
+				dbt.Fatal(err)
+			}
+			return n
+		}
+
+		if n := count(WithTimestampBound(ctx, spanner.ReadTimestamp(commitTimestamp)), conn); n != 1 {
+			dbt.Errorf("expected 1 row at the read timestamp, got %d", n)
+		}
+
+		stmt := fmt.Sprintf("SET READ_ONLY_STALENESS = 'READ_TIMESTAMP %s'", commitTimestamp.Format(time.RFC3339Nano))
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			dbt.Fatal(err)
+		}
+		if n := count(ctx, conn); n != 1 {
+			dbt.Errorf("expected 1 row at the read timestamp, got %d", n)
+		}
+		tx, err := conn.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+		if err != nil {
+			dbt.Fatal(err)
+		}
+		if n := count(ctx, tx); n != 1 {
+			dbt.Errorf("expected 1 row in the read-only transaction, got %d", n)
+		}
+		if err := tx.Commit(); err != nil {
+			dbt.Fatal(err)
+		}
+
+		if n := count(WithTimestampBound(ctx, spanner.StrongRead()), conn); n != 2 {
+			dbt.Errorf("expected 2 rows for a strong read, got %d", n)
+		}
+	})
+}
+
+func TestTransactionTimestamps(t *testing.T) {
+	runTests(t, dsn, func(dbt *DBTest) {
+		ctx := context.Background()
+		conn, err := dbt.db.Conn(ctx)
+		if err != nil {
+			dbt.Fatal(err)
+		}
+		defer conn.Close()
+
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			dbt.Fatal(err)
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT INTO test (Id, Value) VALUES ('userId1', true)"); err != nil {
+			dbt.Fatal(err)
+		}
+		if err := tx.Commit(); err != nil {
+			dbt.Fatal(err)
+		}
+
+		var commitTimestamp, shown time.Time
+		if err := conn.Raw(func(driverConn interface{}) error {
+			commitTimestamp, err = driverConn.(SpannerConn).CommitTimestamp()
+			return err
+		}); err != nil {
+			dbt.Fatal(err)
+		}
+		if err := conn.QueryRowContext(ctx, "SHOW VARIABLE COMMIT_TIMESTAMP").Scan(&shown); err != nil {
+			dbt.Fatal(err)
+		}
+		if commitTimestamp.IsZero() || !shown.Equal(commitTimestamp) {
+			dbt.Errorf("expected commit timestamp %s, got %s", commitTimestamp, shown)
+		}
+
+		// The row is visible at the commit timestamp.
+		tx, err = conn.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+		if err != nil {
+			dbt.Fatal(err)
+		}
+		var count int64
+		if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM test").Scan(&count); err != nil {
+			dbt.Fatal(err)
+		}
+		if err := tx.Commit(); err != nil {
+			dbt.Fatal(err)
+		}
+		var readTimestamp time.Time
+		if err := conn.QueryRowContext(ctx, "SHOW VARIABLE READ_TIMESTAMP").Scan(&readTimestamp); err != nil {
+			dbt.Fatal(err)
+		}
+		if count != 1 || readTimestamp.Before(commitTimestamp) {
+			dbt.Errorf("expected to read 1 row at or after %s, read %d rows at %s", commitTimestamp, count, readTimestamp)
+		}
+	})
+}
+
+func TestRetryAbortsInternally(t *testing.T) {
+	// The replay of aborted transactions is tested with fake transactions in
+	// read_write_transaction_test.go, as aborts cannot be forced on Cloud
+	// Spanner. This tests that transactions which are not aborted work as usual.
+	runTests(t, dsn+"?retryAbortsInternally=true", func(dbt *DBTest) {
+		ctx := context.Background()
+		tx, err := dbt.db.BeginTx(ctx, nil)
+		if err != nil {
+			dbt.Fatal(err)
+		}
+		var count int64
+		if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM test").Scan(&count); err != nil {
+			dbt.Fatal(err)
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT INTO test (Id, Value) VALUES ('userId1', true)"); err != nil {
+			dbt.Fatal(err)
+		}
+		if err := tx.Commit(); err != nil {
+			dbt.Fatal(err)
+		}
+		if err := dbt.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM test").Scan(&count); err != nil {
+			dbt.Fatal(err)
+		}
+		if count != 1 {
+			dbt.Errorf("expected 1 row, got %d", count)
+		}
+	})
+}
+
+func TestRunTransaction(t *testing.T) {
+	runTests(t, dsn, func(dbt *DBTest) {
+		ctx := context.Background()
+		attempts := 0
+		err := RunTransaction(ctx, dbt.db, nil, func(tx *sql.Tx) error {
+			attempts++
+			if _, err := tx.ExecContext(ctx, "INSERT INTO test (Id, Value) VALUES ('userId1', true)"); err != nil {
+				return err
+			}
+			if attempts == 1 {
+				return status.Error(codes.Aborted, "aborted")
+			}
+			return nil
+		})
+		if err != nil {
+			dbt.Fatal(err)
+		}
+		if attempts != 2 {
+			dbt.Errorf("expected 2 attempts, got %d", attempts)
+		}
+		var count int64
+		if err := dbt.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM test").Scan(&count); err != nil {
+			dbt.Fatal(err)
+		}
+		if count != 1 {
+			dbt.Errorf("expected 1 row, got %d", count)
+		}
+
+		// Other errors are returned without retrying.
+		attempts = 0
+		err = RunTransaction(ctx, dbt.db, nil, func(tx *sql.Tx) error {
+			attempts++
+			_, err := tx.ExecContext(ctx, "INSERT INTO test (Id, Value) VALUES ('userId1', true)")
+			return err
+		})
+		if spanner.ErrCode(err) != codes.AlreadyExists || attempts != 1 {
+			dbt.Errorf("expected AlreadyExists after 1 attempt, got %v after %d attempts", err, attempts)
+		}
+	})
+}
+
+func TestErrors(t *testing.T) {
+	runTests(t, dsn, func(dbt *DBTest) {
+		ctx := context.Background()
+		dbt.mustExec("INSERT INTO test (Id, Value) VALUES ('userId1', true)")
+
+		_, err := dbt.db.ExecContext(ctx, "INSERT INTO test (Id, Value) VALUES ('userId1', false)")
+		var de *DuplicateKeyError
+		if !errors.As(err, &de) || de.Table != "test" {
+			dbt.Fatalf("expected *DuplicateKeyError for table test, got %v", err)
+		}
+		if !errors.Is(err, ErrDuplicateKey) || !errors.Is(err, ErrAlreadyExists) || IsRetryable(err) {
+			dbt.Errorf("expected a duplicate key error which is not retryable, got %v", err)
+		}
+	})
+}
+
+func TestBadConnAfterClientClose(t *testing.T) {
+	runTests(t, dsn, func(dbt *DBTest) {
+		ctx := context.Background()
+		client, err := spanner.NewClient(ctx, dsn)
+		if err != nil {
+			dbt.Fatal(err)
+		}
+		db := sql.OpenDB(NewConnectorWithClient(client))
+		defer db.Close()
+		if err := db.PingContext(ctx); err != nil {
+			dbt.Fatal(err)
+		}
+
+		// The error of the closed client is returned, as retrying on other
+		// connections of the same client cannot succeed, and the connections
+		// are discarded by database/sql.
+		client.Close()
+		if _, err := db.ExecContext(ctx, "INSERT INTO test (Id, Value) VALUES ('userId1', true)"); spanner.ErrCode(err) != codes.InvalidArgument {
+			dbt.Errorf("expected the error of the closed client, got %v", err)
+		}
+		if _, err := db.BeginTx(ctx, nil); spanner.ErrCode(err) != codes.InvalidArgument {
+			dbt.Errorf("expected the error of the closed client, got %v", err)
+		}
+		if n := db.Stats().OpenConnections; n != 0 {
+			dbt.Errorf("expected the bad connections to be closed, got %d open connections", n)
+		}
+	})
+}
@@ -1,17 +1,207 @@
 package spannerdriver
 
 import (
+	"context"
 	"log"
 	"os"
+	"regexp"
+	"strings"
 
 	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 var (
 	ErrInvalidConn                = errors.New("invalid connection")
 	ErrWriteInReadOnlyTransaction = errors.New("cannot write in read-only transaction")
+	ErrWriteInReadOnlyConnection  = errors.New("cannot write in read-only connection")
+	ErrDDLInTransaction           = errors.New("cannot execute DDL statements in a transaction")
+	ErrBatchActive                = errors.New("a batch is already active")
+	ErrNoActiveBatch              = errors.New("no batch is active")
+	// ErrAbortedDueToConcurrentModification is returned when an aborted
+	// read-write transaction could not be retried, because a statement
+	// returned a different result in the retry than in the aborted transaction.
+	ErrAbortedDueToConcurrentModification = errors.New("transaction was aborted and could not be retried due to a concurrent modification")
+	// ErrBoundedStalenessInTransaction is returned when a MAX_STALENESS or
+	// MIN_READ_TIMESTAMP read-only staleness would be used for a read-only
+	// transaction, as Cloud Spanner only supports it for single-use reads.
+	ErrBoundedStalenessInTransaction = errors.New("MAX_STALENESS and MIN_READ_TIMESTAMP can only be used for queries outside a transaction")
 )
 
+// Errors returned by Cloud Spanner are wrapped so that errors.Is reports
+// whether they match one of these errors by their gRPC status code. The
+// wrapped *spanner.Error is available through errors.As, and spanner.ErrCode
+// returns the code of the wrapped error.
+var (
+	ErrAborted            = errors.New("transaction was aborted")
+	ErrAlreadyExists      = errors.New("already exists")
+	ErrNotFound           = errors.New("not found")
+	ErrFailedPrecondition = errors.New("failed precondition")
+	ErrDeadlineExceeded   = errors.New("deadline exceeded")
+	// ErrDuplicateKey matches the errors of inserting a row whose key, or
+	// the key of a unique index, already exists. The table and the key are
+	// available through errors.As with *DuplicateKeyError.
+	ErrDuplicateKey = errors.New("duplicate key")
+)
+
+var codeErrors = map[codes.Code]error{
+	codes.Aborted:            ErrAborted,
+	codes.AlreadyExists:      ErrAlreadyExists,
+	codes.NotFound:           ErrNotFound,
+	codes.FailedPrecondition: ErrFailedPrecondition,
+	codes.DeadlineExceeded:   ErrDeadlineExceeded,
+}
+
+// spannerError is an error returned by Cloud Spanner with a gRPC status code.
+type spannerError struct {
+	err  error
+	code codes.Code
+}
+
+func (e *spannerError) Error() string {
+	return e.err.Error()
+}
+
+func (e *spannerError) Unwrap() error {
+	return e.err
+}
+
+func (e *spannerError) Is(target error) bool {
+	return target != nil && codeErrors[e.code] == target
+}
+
+// GRPCStatus returns the status of the wrapped error, so that status.Code and
+// spanner.ErrCode work on the wrapped error. A context error has no status, so
+// one is created with the code of the error.
+func (e *spannerError) GRPCStatus() *status.Status {
+	if s, ok := status.FromError(e.err); ok {
+		return s
+	}
+	return status.New(e.code, e.err.Error())
+}
+
+// DuplicateKeyError is returned when a row is inserted whose key, or the key
+// of a unique index, already exists. It matches ErrDuplicateKey and
+// ErrAlreadyExists with errors.Is.
+type DuplicateKeyError struct {
+	// Table is the table of the row.
+	Table string
+	// Key is the key of the existing row as formatted by Cloud Spanner, e.g.
+	// userId1 for a single key column.
+	Key string
+	// Err is the error returned by Cloud Spanner.
+	Err error
+}
+
+func (e *DuplicateKeyError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *DuplicateKeyError) Unwrap() error {
+	return e.Err
+}
+
+func (e *DuplicateKeyError) Is(target error) bool {
+	return target == ErrDuplicateKey
+}
+
+// GRPCStatus returns the status of the error returned by Cloud Spanner.
+func (e *DuplicateKeyError) GRPCStatus() *status.Status {
+	return status.Convert(e.Err)
+}
+
+// duplicateKeyRegexps match the messages of duplicate key errors, with the
+// table and the key as submatches named table and key. They are the messages
+// of Cloud Spanner and of the emulator, for primary keys and unique indexes.
+var duplicateKeyRegexps = []*regexp.Regexp{
+	regexp.MustCompile(`Row \[(?P<key>.*)\] in table (?P<table>\S+) already exists`),
+	regexp.MustCompile(`Table (?P<table>[^:\s]+): Row \{(?P<key>.*)\} already exists`),
+	regexp.MustCompile(`conflicts with row \[(?P<key>.*)\] in table (?P<table>[^\s.]+)`),
+}
+
+// wrapError wraps an error returned by Cloud Spanner so that it matches the
+// errors of its gRPC status code with errors.Is. context.DeadlineExceeded
+// matches ErrDeadlineExceeded in the same way, as it is returned instead of a
+// status when the deadline passes on the client. Other errors are returned as
+// they are.
+func wrapError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var se *spannerError
+	var de *DuplicateKeyError
+	if errors.As(err, &se) || errors.As(err, &de) {
+		return err
+	}
+	s, ok := status.FromError(err)
+	if !ok {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return &spannerError{err: err, code: codes.DeadlineExceeded}
+		}
+		return err
+	}
+	code := s.Code()
+	wrapped := &spannerError{err: err, code: code}
+	if code != codes.AlreadyExists {
+		return wrapped
+	}
+	for _, re := range duplicateKeyRegexps {
+		if m := re.FindStringSubmatch(s.Message()); m != nil {
+			return &DuplicateKeyError{
+				Table: m[re.SubexpIndex("table")],
+				Key:   m[re.SubexpIndex("key")],
+				Err:   wrapped,
+			}
+		}
+	}
+	return wrapped
+}
+
+// isBadConnError reports whether err shows that the session or the client
+// used by a connection cannot be used anymore: the session was deleted by Cloud
+// Spanner, e.g. after being idle for an hour, or the client was closed.
+func isBadConnError(err error) bool {
+	s, ok := status.FromError(errors.Cause(err))
+	if !ok {
+		return false
+	}
+	switch s.Code() {
+	case codes.NotFound:
+		return isSessionNotFoundError(err)
+	case codes.InvalidArgument:
+		// The session pool of a closed client.
+		return strings.Contains(s.Message(), "invalid session pool")
+	case codes.Canceled:
+		return strings.Contains(s.Message(), "the client connection is closing")
+	}
+	return false
+}
+
+// isSessionNotFoundError reports whether err shows that the session used by a
+// connection was deleted by Cloud Spanner. Unlike a closed client, which is
+// shared by all connections of a connector, a new connection gets another
+// session, so the statement can be retried on it.
+func isSessionNotFoundError(err error) bool {
+	s, ok := status.FromError(errors.Cause(err))
+	return ok && s.Code() == codes.NotFound && strings.Contains(s.Message(), "Session not found")
+}
+
+// IsRetryable reports whether err is caused by an aborted transaction, which
+// succeeds if it is retried from the start, e.g. by RunTransaction.
+func IsRetryable(err error) bool {
+	if errors.Is(err, ErrAborted) || errors.Is(err, ErrAbortedDueToConcurrentModification) {
+		return true
+	}
+	// Errors which are not wrapped, e.g. the errors of spanner.Client.
+	for ; err != nil; err = errors.Unwrap(err) {
+		if status.Code(err) == codes.Aborted {
+			return true
+		}
+	}
+	return false
+}
+
 // Logger is used to log critical error messages.
 type Logger interface {
 	Print(v ...interface{})
@@ -0,0 +1,135 @@
+package spannerdriver
+
+import (
+	"context"
+	"testing"
+
+	"cloud.google.com/go/spanner"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestWrapError(t *testing.T) {
+	sentinels := []error{ErrAborted, ErrAlreadyExists, ErrNotFound, ErrFailedPrecondition, ErrDeadlineExceeded, ErrDuplicateKey}
+	tests := []struct {
+		code codes.Code
+		want error
+	}{
+		{codes.Aborted, ErrAborted},
+		{codes.AlreadyExists, ErrAlreadyExists},
+		{codes.NotFound, ErrNotFound},
+		{codes.FailedPrecondition, ErrFailedPrecondition},
+		{codes.DeadlineExceeded, ErrDeadlineExceeded},
+		{codes.InvalidArgument, nil},
+	}
+	for _, tt := range tests {
+		err := wrapError(spanner.ToSpannerError(status.Error(tt.code, "error")))
+		for _, sentinel := range sentinels {
+			if got := errors.Is(err, sentinel); got != (sentinel == tt.want) {
+				t.Errorf("%s: errors.Is(err, %v) = %v", tt.code, sentinel, got)
+			}
+		}
+		var se *spanner.Error
+		if !errors.As(err, &se) {
+			t.Errorf("%s: expected errors.As to return the *spanner.Error", tt.code)
+		}
+		if code := spanner.ErrCode(err); code != tt.code {
+			t.Errorf("%s: expected spanner.ErrCode to return %s, got %s", tt.code, tt.code, code)
+		}
+		if wrapError(err) != err {
+			t.Errorf("%s: expected wrapped error not to be wrapped again", tt.code)
+		}
+	}
+
+	// The deadline of a context passed on the client.
+	for _, cause := range []error{context.DeadlineExceeded, errors.Wrap(context.DeadlineExceeded, "wait")} {
+		err := wrapError(cause)
+		if !errors.Is(err, ErrDeadlineExceeded) || !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("%v: expected ErrDeadlineExceeded and context.DeadlineExceeded, got %v", cause, err)
+		}
+		if code := spanner.ErrCode(err); code != codes.DeadlineExceeded {
+			t.Errorf("%v: expected spanner.ErrCode to return DeadlineExceeded, got %s", cause, code)
+		}
+	}
+
+	for _, err := range []error{nil, ErrInvalidConn, errors.New("error"), context.Canceled} {
+		if got := wrapError(err); got != err {
+			t.Errorf("expected %v not to be wrapped, got %v", err, got)
+		}
+	}
+}
+
+func TestDuplicateKeyError(t *testing.T) {
+	tests := []struct {
+		msg        string
+		table, key string
+	}{
+		{"Row [userId1] in table test already exists", "test", "userId1"},
+		{`Table test: Row {String("userId1")} already exists.`, "test", `String("userId1")`},
+		{"Unique index violation on index TestByValue at index key [true,userId2]; It conflicts with row [userId1] in table test.", "test", "userId1"},
+	}
+	for _, tt := range tests {
+		err := wrapError(spanner.ToSpannerError(status.Error(codes.AlreadyExists, tt.msg)))
+		var de *DuplicateKeyError
+		if !errors.As(err, &de) {
+			t.Errorf("%s: expected *DuplicateKeyError, got %T", tt.msg, err)
+			continue
+		}
+		if de.Table != tt.table || de.Key != tt.key {
+			t.Errorf("%s: expected table %q and key %q, got %q and %q", tt.msg, tt.table, tt.key, de.Table, de.Key)
+		}
+		if !errors.Is(err, ErrDuplicateKey) || !errors.Is(err, ErrAlreadyExists) {
+			t.Errorf("%s: expected the error to match ErrDuplicateKey and ErrAlreadyExists", tt.msg)
+		}
+		if spanner.ErrCode(err) != codes.AlreadyExists {
+			t.Errorf("%s: expected spanner.ErrCode to return AlreadyExists, got %s", tt.msg, spanner.ErrCode(err))
+		}
+	}
+
+	err := wrapError(spanner.ToSpannerError(status.Error(codes.AlreadyExists, "Duplicate name in schema: test.")))
+	if errors.Is(err, ErrDuplicateKey) || !errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("expected only ErrAlreadyExists to match %v", err)
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{status.Error(codes.Aborted, "aborted"), true},
+		{errors.Wrap(status.Error(codes.Aborted, "aborted"), "commit"), true},
+		{wrapError(spanner.ToSpannerError(status.Error(codes.Aborted, "aborted"))), true},
+		{ErrAbortedDueToConcurrentModification, true},
+		{status.Error(codes.NotFound, "not found"), false},
+		{errors.New("error"), false},
+	}
+	for _, tt := range tests {
+		if got := IsRetryable(tt.err); got != tt.want {
+			t.Errorf("IsRetryable(%v): expected %v, got %v", tt.err, tt.want, got)
+		}
+	}
+}
+
+func TestIsBadConnError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{spanner.ToSpannerError(status.Error(codes.NotFound, "Session not found: projects/p/instances/i/databases/d/sessions/s")), true},
+		{errors.Wrap(status.Error(codes.NotFound, "Session not found: s"), "query"), true},
+		{spanner.ToSpannerError(status.Error(codes.InvalidArgument, "invalid session pool")), true},
+		{spanner.ToSpannerError(status.Error(codes.Canceled, "grpc: the client connection is closing")), true},
+		{spanner.ToSpannerError(status.Error(codes.NotFound, "Table not found: unknown")), false},
+		{spanner.ToSpannerError(status.Error(codes.Canceled, "context canceled")), false},
+		{errors.New("Session not found"), false},
+	}
+	for _, tt := range tests {
+		if got := isBadConnError(tt.err); got != tt.want {
+			t.Errorf("isBadConnError(%v): expected %v, got %v", tt.err, tt.want, got)
+		}
+	}
+}
@@ -3,15 +3,16 @@ module github.com/yuemori/go-sql-driver-spanner
 go 1.17
 
 require (
+	cloud.google.com/go v0.97.0
 	cloud.google.com/go/spanner v1.27.0
 	github.com/pkg/errors v0.9.1
 	google.golang.org/api v0.58.0
 	google.golang.org/genproto v0.0.0-20211104193956-4c6863e31247
 	google.golang.org/grpc v1.42.0
+	google.golang.org/protobuf v1.27.1
 )
 
 require (
-	cloud.google.com/go v0.97.0 // indirect
 	github.com/golang/groupcache v0.0.0-20200121045136-8c9f03a8e57e // indirect
 	github.com/golang/protobuf v1.5.2 // indirect
 	github.com/google/go-cmp v0.5.6 // indirect
@@ -23,5 +24,4 @@ require (
 	golang.org/x/text v0.3.6 // indirect
 	golang.org/x/xerrors v0.0.0-20200804184101-5ec99f83aff1 // indirect
 	google.golang.org/appengine v1.6.7 // indirect
-	google.golang.org/protobuf v1.27.1 // indirect
 )
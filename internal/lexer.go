@@ -0,0 +1,159 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+)
+
+// param is a query parameter found in a statement.
+type param struct {
+	// name is the name of the parameter without the leading '@', or empty
+	// for a positional parameter '?'.
+	name string
+	// start and end are the byte offsets of the parameter in the statement.
+	start, end int
+}
+
+// parseParams returns the named and positional parameters of the statement q
+// in order of their appearance. String, bytes and raw literals (including triple-quoted ones),
+// comments, backquoted identifiers, statement hints such as
+// @{FORCE_INDEX=Idx} and system variables such as @@version are skipped.
+func parseParams(q string) ([]param, error) {
+	var params []param
+	for i := 0; i < len(q); {
+		c := q[i]
+		switch {
+		case c == '\'' || c == '"':
+			end, err := skipString(q, i)
+			if err != nil {
+				return nil, err
+			}
+			i = end
+		case c == '`':
+			end, err := skipQuoted(q, i, i+1, "`", "quoted identifier")
+			if err != nil {
+				return nil, err
+			}
+			i = end
+		case isCommentStart(q, i):
+			end, err := skipComment(q, i)
+			if err != nil {
+				return nil, err
+			}
+			i = end
+		case c == '@':
+			switch {
+			case strings.HasPrefix(q[i:], "@{"):
+				// A statement, table or join hint.
+				i += 2
+			case strings.HasPrefix(q[i:], "@@"):
+				// A system variable.
+				i = scanIdent(q, i+2)
+			default:
+				end := scanIdent(q, i+1)
+				if end > i+1 {
+					params = append(params, param{name: q[i+1 : end], start: i, end: end})
+					i = end
+				} else {
+					i++
+				}
+			}
+		case c == '?':
+			params = append(params, param{start: i, end: i + 1})
+			i++
+		case isIdentStart(c):
+			// Skip whole identifiers and keywords so that string prefixes
+			// such as r, b and rb are not mistaken for anything else.
+			i = scanIdent(q, i)
+		default:
+			i++
+		}
+	}
+	return params, nil
+}
+
+// firstKeyword returns the first keyword of the statement q, skipping
+// leading whitespace and comments.
+func firstKeyword(q string) string {
+	for i := 0; i < len(q); {
+		switch {
+		case strings.IndexByte(" \t\n\r\f\v", q[i]) >= 0:
+			i++
+		case isCommentStart(q, i):
+			end, err := skipComment(q, i)
+			if err != nil {
+				return ""
+			}
+			i = end
+		default:
+			return q[i:scanIdent(q, i)]
+		}
+	}
+	return ""
+}
+
+func isCommentStart(q string, i int) bool {
+	return q[i] == '#' || strings.HasPrefix(q[i:], "--") || strings.HasPrefix(q[i:], "/*")
+}
+
+// skipComment returns the position after the comment which starts at
+// position start of q.
+func skipComment(q string, start int) (int, error) {
+	if strings.HasPrefix(q[start:], "/*") {
+		j := strings.Index(q[start+2:], "*/")
+		if j < 0 {
+			return 0, fmt.Errorf("unterminated comment at position %d", start)
+		}
+		return start + j + 4, nil
+	}
+	if j := strings.IndexByte(q[start:], '\n'); j >= 0 {
+		return start + j + 1, nil
+	}
+	return len(q), nil
+}
+
+// skipString returns the position after the string or bytes literal which
+// starts at position start of q. Raw literals are lexed in the same way, as
+// a backslash cannot precede the closing quote in those either.
+func skipString(q string, start int) (int, error) {
+	quote := q[start : start+1]
+	if triple := strings.Repeat(quote, 3); strings.HasPrefix(q[start:], triple) {
+		return skipQuoted(q, start, start+3, triple, "string literal")
+	}
+	return skipQuoted(q, start, start+1, quote, "string literal")
+}
+
+// skipQuoted returns the position after the first occurrence of the closing
+// delimiter in q at or after position i, skipping backslash escapes.
+func skipQuoted(q string, start, i int, delim, kind string) (int, error) {
+	for i < len(q) {
+		switch {
+		case q[i] == '\\':
+			i += 2
+		case strings.HasPrefix(q[i:], delim):
+			return i + len(delim), nil
+		default:
+			i++
+		}
+	}
+	return 0, fmt.Errorf("unterminated %s at position %d", kind, start)
+}
+
+// scanIdent returns the position after the identifier which starts at
+// position i of q, or i if there is none.
+func scanIdent(q string, i int) int {
+	if i >= len(q) || !isIdentStart(q[i]) {
+		return i
+	}
+	for i++; i < len(q) && isIdentPart(q[i]); i++ {
+	}
+	return i
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || '0' <= c && c <= '9'
+}
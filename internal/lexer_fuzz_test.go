@@ -0,0 +1,104 @@
+//go:build go1.18
+// +build go1.18
+
+package internal
+
+import (
+	"strings"
+	"testing"
+)
+
+func FuzzParseParams(f *testing.F) {
+	for _, q := range []string{
+		"SELECT * FROM Users WHERE id = @id",
+		"SELECT 'me@example.com', \"@a\", '''@b''', r'\\'@c', b\"\"\"@d\"\"\", @e",
+		"SELECT `col@a` FROM Users@{FORCE_INDEX=Idx} -- @b\nWHERE a = @c # @d\n/* @e */",
+		"SELECT @@version, @ a, @1",
+		"SELECT * FROM Users WHERE id = ? AND name = '?'",
+		"SELECT 'a",
+		"SELECT /* a",
+	} {
+		f.Add(q)
+	}
+	f.Fuzz(func(t *testing.T, q string) {
+		params, err := parseParams(q)
+		if err != nil {
+			return
+		}
+		end := 0
+		for _, p := range params {
+			want := "@" + p.name
+			if p.name == "" {
+				want = "?"
+			}
+			if p.start < end || p.end > len(q) || q[p.start:p.end] != want {
+				t.Fatalf("invalid parameter %+v in %q", p, q)
+			}
+			if p.name != "" && !isIdentStart(p.name[0]) {
+				t.Fatalf("invalid parameter name %q in %q", p.name, q)
+			}
+			end = p.end
+		}
+	})
+}
+
+// FuzzParseParamsInLiteral checks that nothing inside a literal or a comment
+// is returned as a parameter.
+func FuzzParseParamsInLiteral(f *testing.F) {
+	for _, s := range []string{"@a", "?", "me@example.com", "'@a'", "\\", "*/ @a", "\n@a"} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		escaped := strings.NewReplacer(`\`, `\\`, `'`, `\'`, `"`, `\"`).Replace(s)
+		for _, q := range []string{
+			"SELECT '" + escaped + "'",
+			`SELECT r"` + escaped + `"`,
+			"SELECT b'''" + escaped + "'''",
+			"SELECT `" + strings.NewReplacer(`\`, `\\`, "`", "\\`").Replace(s) + "`",
+			"SELECT 1 -- " + strings.ReplaceAll(s, "\n", " "),
+			"SELECT 1 /* " + strings.ReplaceAll(s, "*/", "* /") + " */",
+		} {
+			params, err := parseParams(q + ", @x")
+			if err != nil {
+				t.Fatalf("parseParams(%q) returned error: %+v", q, err)
+			}
+			if len(params) > 1 || (len(params) == 1 && params[0].name != "x") {
+				t.Fatalf("parseParams(%q) returned %+v", q, params)
+			}
+		}
+	})
+}
+
+// FuzzRewritePositionalParams checks that the rewritten query has as many
+// named parameters as the original query has positional ones.
+func FuzzRewritePositionalParams(f *testing.F) {
+	for _, q := range []string{
+		"SELECT * FROM Users WHERE id = ? AND name = ?",
+		"SELECT '?', \"?\", `?`, ? -- ?",
+		"SELECT @a",
+	} {
+		f.Add(q)
+	}
+	f.Fuzz(func(t *testing.T, q string) {
+		params, err := parseParams(q)
+		if err != nil {
+			return
+		}
+		rewritten, err := RewritePositionalParams(q)
+		if err != nil {
+			return
+		}
+		got, err := parseParams(rewritten)
+		if err != nil {
+			t.Fatalf("parseParams(%q) returned error: %+v", rewritten, err)
+		}
+		if len(got) != len(params) {
+			t.Fatalf("%q was rewritten to %q with %d parameters", q, rewritten, len(got))
+		}
+		for _, p := range got {
+			if p.name == "" {
+				t.Fatalf("%q was rewritten to %q with a positional parameter", q, rewritten)
+			}
+		}
+	})
+}
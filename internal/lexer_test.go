@@ -0,0 +1,139 @@
+package internal
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseParams(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{"simple", "SELECT * FROM Users WHERE id = @id AND name = @name", []string{"id", "name"}},
+		{"adjacent", "SELECT @a+@b,@c", []string{"a", "b", "c"}},
+		{"underscore", "SELECT @_a1", []string{"_a1"}},
+		{"repeated", "SELECT @a, @a", []string{"a", "a"}},
+		{"single-quoted string", "SELECT 'me@example.com', @a", []string{"a"}},
+		{"double-quoted string", `SELECT "me@example.com", @a`, []string{"a"}},
+		{"escaped quote", `SELECT 'it\'s @b', "say \"@c\"", @a`, []string{"a"}},
+		{"escaped backslash", `SELECT '\\', @a`, []string{"a"}},
+		{"triple-quoted string", "SELECT '''it's\n@b''', \"\"\"a\"@c\"\"\", @a", []string{"a"}},
+		{"empty strings", `SELECT '', "", @a`, []string{"a"}},
+		{"raw string", `SELECT r'\@b', R"@c", @a`, []string{"a"}},
+		{"raw escaped quote", `SELECT r'\'@b', @a`, []string{"a"}},
+		{"bytes", `SELECT b'@b', rb"@c", BR'''@d''', @a`, []string{"a"}},
+		{"dash comment", "SELECT @a -- WHERE id = @b\n, @c", []string{"a", "c"}},
+		{"hash comment", "SELECT @a # @b\n, @c", []string{"a", "c"}},
+		{"block comment", "SELECT /* @b\n@c */ @a", []string{"a"}},
+		{"comment at end", "SELECT @a -- @b", []string{"a"}},
+		{"backquoted identifier", "SELECT `col@b` FROM `Users` WHERE id = @a", []string{"a"}},
+		{"statement hint", "@{USE_ADDITIONAL_PARALLELISM=TRUE} SELECT @a", []string{"a"}},
+		{"table hint", "SELECT * FROM Users@{FORCE_INDEX=UsersByName} WHERE name = @a", []string{"a"}},
+		{"system variable", "SELECT @@version, @a", []string{"a"}},
+		{"lone at", "SELECT @ a, @1, @a", []string{"a"}},
+		{"minus", "SELECT 1-@a-2", []string{"a"}},
+		{"division", "SELECT 1/@a", []string{"a"}},
+		{"positional", "SELECT ?, '?', `?`, @a -- ?", []string{"", "a"}},
+		{"no params", "SELECT 1", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, err := parseParams(tt.query)
+			if err != nil {
+				t.Fatalf("parseParams returned error: %+v", err)
+			}
+			var got []string
+			for _, p := range params {
+				want := "@" + p.name
+				if p.name == "" {
+					want = "?"
+				}
+				if s := tt.query[p.start:p.end]; s != want {
+					t.Errorf("expected %q at [%d:%d], got %q", want, p.start, p.end, s)
+				}
+				got = append(got, p.name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParseParamsError(t *testing.T) {
+	for _, q := range []string{
+		"SELECT 'a",
+		`SELECT "a\"`,
+		"SELECT '''a''",
+		"SELECT `a",
+		"SELECT /* a",
+		`SELECT 'a\`,
+	} {
+		if _, err := parseParams(q); err == nil {
+			t.Errorf("%q: expected error, got nil", q)
+		}
+	}
+}
+
+func TestNamedValueParamNames(t *testing.T) {
+	tests := []struct {
+		query string
+		n     int
+		want  []string
+	}{
+		{"SELECT @a, @b, @a", -1, []string{"a", "b"}},
+		{"SELECT @a, @b, @a", 2, []string{"a", "b"}},
+		{"SELECT @a, @b, @c", 2, []string{"a", "b"}},
+		{"SELECT 'me@example.com', @a", 1, []string{"a"}},
+		{"SELECT 1", 0, nil},
+	}
+	for _, tt := range tests {
+		got, err := NamedValueParamNames(tt.query, tt.n)
+		if err != nil {
+			t.Errorf("%q: NamedValueParamNames returned error: %+v", tt.query, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: expected %v, got %v", tt.query, tt.want, got)
+		}
+	}
+
+	if _, err := NamedValueParamNames("SELECT 'me@example.com', @a", 2); err == nil {
+		t.Error("expected error for too many arguments, got nil")
+	}
+	if _, err := NamedValueParamNames("SELECT ?", 1); err == nil {
+		t.Error("expected error for positional parameter, got nil")
+	}
+}
+
+func TestRewritePositionalParams(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"SELECT * FROM Users WHERE id = ? AND name = ?", "SELECT * FROM Users WHERE id = @p1 AND name = @p2"},
+		{"SELECT ?+?", "SELECT @p1+@p2"},
+		{"SELECT ?a, @?", "SELECT @p1 a, @ @p2"},
+		{"SELECT '?', \"?\", `?`, ? -- ?", "SELECT '?', \"?\", `?`, @p1 -- ?"},
+		{"SELECT * FROM Users WHERE id = @id", "SELECT * FROM Users WHERE id = @id"},
+		{"SELECT 1", "SELECT 1"},
+	}
+	for _, tt := range tests {
+		got, err := RewritePositionalParams(tt.query)
+		if err != nil {
+			t.Errorf("%q: RewritePositionalParams returned error: %+v", tt.query, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q: expected %q, got %q", tt.query, tt.want, got)
+		}
+	}
+
+	for _, q := range []string{"SELECT ?, @a", "SELECT @a, ?", "SELECT ?, 'a"} {
+		if _, err := RewritePositionalParams(q); err == nil {
+			t.Errorf("%q: expected error, got nil", q)
+		}
+	}
+}
@@ -2,19 +2,77 @@ package internal
 
 import (
 	"fmt"
-	"regexp"
+	"strconv"
+	"strings"
 )
 
-var namedValueParamNameRegex = regexp.MustCompile("@(\\w+)")
-
+// NamedValueParamNames returns the names of the first n distinct parameters
+// of the query q in order of their first appearance, or all of them if n is -1.
+// Literals, comments, quoted identifiers and hints are not searched for parameters.
+// Positional parameters must have been rewritten by RewritePositionalParams.
 func NamedValueParamNames(q string, n int) ([]string, error) {
+	params, err := parseParams(q)
+	if err != nil {
+		return nil, err
+	}
 	var names []string
-	matches := namedValueParamNameRegex.FindAllStringSubmatch(q, n)
-	if m := len(matches); n != -1 && m < n {
-		return nil, fmt.Errorf("query has %d placeholders but %d arguments are provided", m, n)
+	seen := make(map[string]bool)
+	for _, p := range params {
+		if p.name == "" {
+			return nil, fmt.Errorf("unexpected positional parameter at position %d", p.start)
+		}
+		if n != -1 && len(names) == n {
+			break
+		}
+		if !seen[p.name] {
+			seen[p.name] = true
+			names = append(names, p.name)
+		}
 	}
-	for _, m := range matches {
-		names = append(names, m[1])
+	if m := len(names); n != -1 && m < n {
+		return nil, fmt.Errorf("query has %d placeholders but %d arguments are provided", m, n)
 	}
 	return names, nil
 }
+
+// RewritePositionalParams replaces the positional parameters '?' of the query q
+// with the named parameters @p1, @p2, ..., @pN. A query without positional
+// parameters is returned as it is, and a query with both positional and named
+// parameters is rejected.
+func RewritePositionalParams(q string) (string, error) {
+	params, err := parseParams(q)
+	if err != nil {
+		return "", err
+	}
+	var positional []param
+	for _, p := range params {
+		if p.name == "" {
+			positional = append(positional, p)
+		}
+	}
+	if len(positional) == 0 {
+		return q, nil
+	}
+	if len(positional) < len(params) {
+		return "", fmt.Errorf("query mixes positional and named parameters")
+	}
+
+	var b strings.Builder
+	last := 0
+	for i, p := range positional {
+		b.WriteString(q[last:p.start])
+		// Keep the parameter apart from an adjacent '@' or identifier, as in
+		// "@?" or "?a", so that it is not merged into another token.
+		if p.start > 0 && q[p.start-1] == '@' {
+			b.WriteByte(' ')
+		}
+		b.WriteString("@p")
+		b.WriteString(strconv.Itoa(i + 1))
+		if p.end < len(q) && isIdentPart(q[p.end]) {
+			b.WriteByte(' ')
+		}
+		last = p.end
+	}
+	b.WriteString(q[last:])
+	return b.String(), nil
+}
@@ -0,0 +1,13 @@
+package internal
+
+import "strings"
+
+// IsDDL reports whether the statement q is a DDL statement, i.e. whether it
+// starts with CREATE, ALTER or DROP.
+func IsDDL(q string) bool {
+	switch strings.ToUpper(firstKeyword(q)) {
+	case "CREATE", "ALTER", "DROP":
+		return true
+	}
+	return false
+}
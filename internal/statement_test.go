@@ -0,0 +1,28 @@
+package internal
+
+import "testing"
+
+func TestIsDDL(t *testing.T) {
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{"CREATE TABLE Users (Id STRING(36)) PRIMARY KEY (Id)", true},
+		{"create index UsersByName ON Users (Name)", true},
+		{"  \n\tALTER TABLE Users ADD COLUMN Name STRING(MAX)", true},
+		{"-- drop the index\nDROP INDEX UsersByName", true},
+		{"/* comment */ DROP TABLE Users", true},
+		{"# comment\nCREATE VIEW V SQL SECURITY INVOKER AS SELECT 1", true},
+		{"SELECT * FROM Users", false},
+		{"INSERT INTO Users (Id) VALUES ('CREATE')", false},
+		{"@{USE_ADDITIONAL_PARALLELISM=TRUE} SELECT 1", false},
+		{"CREATED", false},
+		{"/* CREATE", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := IsDDL(tt.query); got != tt.want {
+			t.Errorf("%q: expected %v, got %v", tt.query, tt.want, got)
+		}
+	}
+}
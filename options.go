@@ -0,0 +1,124 @@
+package spannerdriver
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"github.com/pkg/errors"
+)
+
+// AutocommitDMLMode determines how DML statements are executed outside a
+// transaction.
+type AutocommitDMLMode int
+
+const (
+	// Transactional executes every DML statement in its own read-write
+	// transaction. This is the default.
+	Transactional AutocommitDMLMode = iota
+	// PartitionedNonAtomic executes DML statements as Partitioned DML, which
+	// is not atomic and is not subject to the mutation limit of transactions.
+	// The number of affected rows is a lower bound.
+	PartitionedNonAtomic
+)
+
+func (m AutocommitDMLMode) String() string {
+	switch m {
+	case Transactional:
+		return "TRANSACTIONAL"
+	case PartitionedNonAtomic:
+		return "PARTITIONED_NON_ATOMIC"
+	}
+	return "UNKNOWN"
+}
+
+func parseAutocommitDMLMode(s string) (AutocommitDMLMode, error) {
+	switch strings.ToUpper(s) {
+	case "TRANSACTIONAL":
+		return Transactional, nil
+	case "PARTITIONED_NON_ATOMIC":
+		return PartitionedNonAtomic, nil
+	}
+	return 0, errors.Errorf("invalid AUTOCOMMIT_DML_MODE value: %q", s)
+}
+
+type autocommitDMLModeKey struct{}
+
+// WithAutocommitDMLMode returns a copy of ctx with the autocommit DML mode
+// set, which overrides the mode of the connection for the statements executed
+// with the returned context.
+func WithAutocommitDMLMode(ctx context.Context, mode AutocommitDMLMode) context.Context {
+	return context.WithValue(ctx, autocommitDMLModeKey{}, mode)
+}
+
+func autocommitDMLModeFromContext(ctx context.Context) (AutocommitDMLMode, bool) {
+	mode, ok := ctx.Value(autocommitDMLModeKey{}).(AutocommitDMLMode)
+	return mode, ok
+}
+
+type timestampBoundKey struct{}
+
+// WithTimestampBound returns a copy of ctx with the timestamp bound set, which
+// overrides the read-only staleness of the connection for the read-only
+// transactions begun and the queries executed outside a transaction with the
+// returned context. Beginning a read-only transaction with a MaxStaleness or
+// MinReadTimestamp bound fails with ErrBoundedStalenessInTransaction.
+func WithTimestampBound(ctx context.Context, tb spanner.TimestampBound) context.Context {
+	return context.WithValue(ctx, timestampBoundKey{}, tb)
+}
+
+func timestampBoundFromContext(ctx context.Context) (spanner.TimestampBound, bool) {
+	tb, ok := ctx.Value(timestampBoundKey{}).(spanner.TimestampBound)
+	return tb, ok
+}
+
+// parseTimestampBound parses a read-only staleness of one of the forms
+//
+//	STRONG
+//	EXACT_STALENESS <duration>, e.g. EXACT_STALENESS 15s
+//	MAX_STALENESS <duration>
+//	READ_TIMESTAMP <timestamp>, e.g. READ_TIMESTAMP 2021-11-04T10:00:00Z
+//	MIN_READ_TIMESTAMP <timestamp>
+//
+// Durations are parsed by time.ParseDuration and timestamps are in RFC 3339
+// format. MAX_STALENESS and MIN_READ_TIMESTAMP can only be used for queries
+// outside a transaction, see isBoundedStaleness.
+func parseTimestampBound(s string) (spanner.TimestampBound, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 1 && strings.EqualFold(fields[0], "STRONG") {
+		return spanner.StrongRead(), nil
+	}
+	if len(fields) != 2 {
+		return spanner.TimestampBound{}, errors.Errorf("invalid read-only staleness: %q", s)
+	}
+	switch strings.ToUpper(fields[0]) {
+	case "EXACT_STALENESS", "MAX_STALENESS":
+		d, err := time.ParseDuration(fields[1])
+		if err != nil || d < 0 {
+			return spanner.TimestampBound{}, errors.Errorf("invalid staleness in read-only staleness: %q", s)
+		}
+		if strings.EqualFold(fields[0], "EXACT_STALENESS") {
+			return spanner.ExactStaleness(d), nil
+		}
+		return spanner.MaxStaleness(d), nil
+	case "READ_TIMESTAMP", "MIN_READ_TIMESTAMP":
+		t, err := time.Parse(time.RFC3339Nano, fields[1])
+		if err != nil {
+			return spanner.TimestampBound{}, errors.Errorf("invalid timestamp in read-only staleness: %q", s)
+		}
+		if strings.EqualFold(fields[0], "READ_TIMESTAMP") {
+			return spanner.ReadTimestamp(t), nil
+		}
+		return spanner.MinReadTimestamp(t), nil
+	}
+	return spanner.TimestampBound{}, errors.Errorf("invalid read-only staleness: %q", s)
+}
+
+// isBoundedStaleness reports whether tb is a MaxStaleness or MinReadTimestamp
+// bound, which Cloud Spanner only supports for single-use reads. The mode of
+// a TimestampBound is unexported, so it is read from its string form.
+func isBoundedStaleness(tb spanner.TimestampBound) bool {
+	s := tb.String()
+	return strings.HasPrefix(s, "(maxStaleness:") || strings.HasPrefix(s, "(minReadTimestamp:")
+}
@@ -0,0 +1,117 @@
+package spannerdriver
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/spanner"
+)
+
+func TestSetAutocommitDMLMode(t *testing.T) {
+	ctx := context.Background()
+	c := newTestConn()
+
+	if _, err := c.ExecContext(ctx, "SET AUTOCOMMIT_DML_MODE = 'PARTITIONED_NON_ATOMIC'", nil); err != nil {
+		t.Fatal(err)
+	}
+	if mode := c.autocommitDMLModeOf(ctx); mode != PartitionedNonAtomic {
+		t.Errorf("expected PARTITIONED_NON_ATOMIC, got %s", mode)
+	}
+	if mode := c.autocommitDMLModeOf(WithAutocommitDMLMode(ctx, Transactional)); mode != Transactional {
+		t.Errorf("expected the context to override the connection, got %s", mode)
+	}
+	if _, err := c.ExecContext(ctx, "SET AUTOCOMMIT_DML_MODE = 'ATOMIC'", nil); err == nil {
+		t.Error("expected error for invalid mode, got nil")
+	}
+
+	if err := c.ResetSession(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if mode := c.autocommitDMLModeOf(ctx); mode != Transactional {
+		t.Errorf("expected ResetSession to reset the mode, got %s", mode)
+	}
+	if mode := c.autocommitDMLModeOf(WithAutocommitDMLMode(ctx, PartitionedNonAtomic)); mode != PartitionedNonAtomic {
+		t.Errorf("expected PARTITIONED_NON_ATOMIC from the context, got %s", mode)
+	}
+}
+
+func TestParseTimestampBound(t *testing.T) {
+	ts := time.Date(2021, 11, 4, 10, 0, 0, 500, time.UTC)
+	tests := []struct {
+		s    string
+		want spanner.TimestampBound
+	}{
+		{"STRONG", spanner.StrongRead()},
+		{"strong", spanner.StrongRead()},
+		{"EXACT_STALENESS 15s", spanner.ExactStaleness(15 * time.Second)},
+		{" max_staleness  100ms ", spanner.MaxStaleness(100 * time.Millisecond)},
+		{"READ_TIMESTAMP 2021-11-04T10:00:00.0000005Z", spanner.ReadTimestamp(ts)},
+		{"MIN_READ_TIMESTAMP 2021-11-04T19:00:00.0000005+09:00", spanner.MinReadTimestamp(ts.In(time.FixedZone("", 9*60*60)))},
+	}
+	for _, tt := range tests {
+		got, err := parseTimestampBound(tt.s)
+		if err != nil {
+			t.Errorf("%q: parseTimestampBound returned error: %+v", tt.s, err)
+			continue
+		}
+		if got.String() != tt.want.String() {
+			t.Errorf("%q: expected %s, got %s", tt.s, tt.want, got)
+		}
+	}
+
+	for _, s := range []string{"", "STRONG 1s", "EXACT_STALENESS", "EXACT_STALENESS -1s", "MAX_STALENESS 15", "READ_TIMESTAMP 2021-11-04", "STALE 1s"} {
+		if _, err := parseTimestampBound(s); err == nil {
+			t.Errorf("%q: expected error, got nil", s)
+		}
+	}
+}
+
+func TestSetReadOnlyStaleness(t *testing.T) {
+	ctx := context.Background()
+	c := newTestConn()
+	c.staleness = spanner.MaxStaleness(time.Second)
+	c.defaultStaleness = c.staleness
+
+	if _, err := c.ExecContext(ctx, "SET READ_ONLY_STALENESS = 'EXACT_STALENESS 15s'", nil); err != nil {
+		t.Fatal(err)
+	}
+	if tb := c.timestampBoundOf(ctx); !reflect.DeepEqual(tb, spanner.ExactStaleness(15*time.Second)) {
+		t.Errorf("expected exact staleness of 15s, got %s", tb)
+	}
+	if tb := c.timestampBoundOf(WithTimestampBound(ctx, spanner.StrongRead())); !reflect.DeepEqual(tb, spanner.StrongRead()) {
+		t.Errorf("expected the context to override the connection, got %s", tb)
+	}
+	if _, err := c.ExecContext(ctx, "SET READ_ONLY_STALENESS = 'EXACT_STALENESS'", nil); err == nil {
+		t.Error("expected error for invalid staleness, got nil")
+	}
+
+	if err := c.ResetSession(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if tb := c.timestampBoundOf(ctx); !reflect.DeepEqual(tb, spanner.MaxStaleness(time.Second)) {
+		t.Errorf("expected ResetSession to restore the default staleness, got %s", tb)
+	}
+}
+
+func TestBeginReadOnlyTransactionWithBoundedStaleness(t *testing.T) {
+	ctx := context.Background()
+	c := newTestConn()
+	c.readOnly = true
+	if _, err := c.BeginTx(WithTimestampBound(ctx, spanner.MaxStaleness(time.Second)), driver.TxOptions{}); !errors.Is(err, ErrBoundedStalenessInTransaction) {
+		t.Errorf("expected ErrBoundedStalenessInTransaction, got %v", err)
+	}
+
+	// Bounded staleness can be set for the queries outside a transaction.
+	for _, s := range []string{"MAX_STALENESS 15s", "MIN_READ_TIMESTAMP 2021-11-04T10:00:00Z"} {
+		if _, err := c.ExecContext(ctx, "SET READ_ONLY_STALENESS = '"+s+"'", nil); err != nil {
+			t.Fatalf("%q: %v", s, err)
+		}
+		if _, err := c.BeginTx(ctx, driver.TxOptions{}); !errors.Is(err, ErrBoundedStalenessInTransaction) {
+			t.Errorf("%q: expected ErrBoundedStalenessInTransaction, got %v", s, err)
+		}
+	}
+}
@@ -0,0 +1,375 @@
+package spannerdriver
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"hash"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"google.golang.org/api/iterator"
+	sppb "google.golang.org/genproto/googleapis/spanner/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/proto"
+)
+
+// maxAbortRetries is the maximum number of times a transaction is retried
+// after aborts before the abort is returned to the caller.
+const maxAbortRetries = 50
+
+// readWriteTransaction is the read-write transaction of a connection.
+//
+// If retryAborts is set, it records the statements executed in the transaction
+// and a checksum of their results. When Cloud Spanner aborts the transaction,
+// it is retried by replaying the statements in a new transaction, and the
+// retry fails with ErrAbortedDueToConcurrentModification if any of them
+// returns a different result than in the aborted transaction.
+type readWriteTransaction struct {
+	tx          stmtBasedTransaction
+	retryAborts bool
+	// begin begins the new transactions in which an aborted transaction is
+	// retried.
+	begin func(ctx context.Context) (stmtBasedTransaction, error)
+	// retries is the number of times the transaction has been retried.
+	retries int
+
+	// statements are the statements to replay when the transaction is retried.
+	statements []retriableStatement
+}
+
+// stmtBasedTransaction is the transaction of a readWriteTransaction. It is
+// implemented by spannerStmtBasedTransaction, and by fakes in tests.
+type stmtBasedTransaction interface {
+	Update(ctx context.Context, stmt spanner.Statement) (int64, error)
+	BatchUpdate(ctx context.Context, stmts []spanner.Statement) ([]int64, error)
+	Query(ctx context.Context, stmt spanner.Statement) rowIterator
+	BufferWrite(ms []*spanner.Mutation) error
+	Commit(ctx context.Context) (time.Time, error)
+	Rollback(ctx context.Context)
+}
+
+// spannerStmtBasedTransaction is the stmtBasedTransaction of a
+// spanner.ReadWriteStmtBasedTransaction.
+type spannerStmtBasedTransaction struct {
+	*spanner.ReadWriteStmtBasedTransaction
+}
+
+func (tx spannerStmtBasedTransaction) Query(ctx context.Context, stmt spanner.Statement) rowIterator {
+	return spannerIterator{tx.ReadWriteStmtBasedTransaction.Query(ctx, stmt)}
+}
+
+// retriableStatement is a statement executed in a readWriteTransaction.
+type retriableStatement interface {
+	// retry executes the statement again in tx and returns
+	// ErrAbortedDueToConcurrentModification if its result has changed.
+	retry(ctx context.Context, tx stmtBasedTransaction) error
+}
+
+func newReadWriteTransaction(ctx context.Context, client *spanner.Client, retryAborts bool) (*readWriteTransaction, error) {
+	begin := func(ctx context.Context) (stmtBasedTransaction, error) {
+		tx, err := spanner.NewReadWriteStmtBasedTransaction(ctx, client)
+		if err != nil {
+			return nil, err
+		}
+		return spannerStmtBasedTransaction{tx}, nil
+	}
+	tx, err := begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &readWriteTransaction{tx: tx, retryAborts: retryAborts, begin: begin}, nil
+}
+
+func (t *readWriteTransaction) Update(ctx context.Context, stmt spanner.Statement) (int64, error) {
+	for {
+		count, err := t.tx.Update(ctx, stmt)
+		if t.shouldRetry(err) {
+			if err := t.retry(ctx, err); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		t.record(&retriableUpdate{stmt: stmt, count: count, err: err})
+		return count, err
+	}
+}
+
+func (t *readWriteTransaction) BatchUpdate(ctx context.Context, stmts []spanner.Statement) ([]int64, error) {
+	for {
+		counts, err := t.tx.BatchUpdate(ctx, stmts)
+		if t.shouldRetry(err) {
+			if err := t.retry(ctx, err); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		t.record(&retriableBatchUpdate{stmts: stmts, counts: counts, err: err})
+		return counts, err
+	}
+}
+
+func (t *readWriteTransaction) Query(ctx context.Context, stmt spanner.Statement) rowIterator {
+	it := t.tx.Query(ctx, stmt)
+	if !t.retryAborts {
+		return it
+	}
+	q := &retriableQuery{tx: t, ctx: ctx, stmt: stmt, it: it, checksum: sha256.New()}
+	t.record(q)
+	return q
+}
+
+func (t *readWriteTransaction) BufferWrite(ms []*spanner.Mutation) error {
+	if err := t.tx.BufferWrite(ms); err != nil {
+		return err
+	}
+	t.record(retriableBufferWrite(ms))
+	return nil
+}
+
+func (t *readWriteTransaction) Commit(ctx context.Context) (time.Time, error) {
+	for {
+		commitTimestamp, err := t.tx.Commit(ctx)
+		if t.shouldRetry(err) {
+			if err := t.retry(ctx, err); err != nil {
+				// The transaction is ended by Commit even if the retry
+				// failed, as database/sql does not roll it back.
+				t.tx.Rollback(ctx)
+				return time.Time{}, err
+			}
+			continue
+		}
+		return commitTimestamp, err
+	}
+}
+
+func (t *readWriteTransaction) Rollback(ctx context.Context) {
+	t.tx.Rollback(ctx)
+}
+
+func (t *readWriteTransaction) record(s retriableStatement) {
+	if t.retryAborts {
+		t.statements = append(t.statements, s)
+	}
+}
+
+func (t *readWriteTransaction) shouldRetry(err error) bool {
+	return t.retryAborts && spanner.ErrCode(err) == codes.Aborted
+}
+
+// retry replays the statements of the aborted transaction in a new
+// transaction, until a replay succeeds or fails for another reason than
+// another abort. The new transaction is rolled back if the replay fails.
+func (t *readWriteTransaction) retry(ctx context.Context, abortErr error) error {
+	for t.retries < maxAbortRetries {
+		t.retries++
+		// Release the session of the aborted transaction.
+		t.tx.Rollback(ctx)
+		select {
+		case <-time.After(retryDelay(abortErr, t.retries)):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		tx, err := t.begin(ctx)
+		if err != nil {
+			return err
+		}
+		t.tx = tx
+
+		err = t.replay(ctx)
+		if err == nil {
+			return nil
+		}
+		t.tx.Rollback(ctx)
+		if spanner.ErrCode(err) != codes.Aborted {
+			return err
+		}
+		abortErr = err
+	}
+	t.tx.Rollback(ctx)
+	return abortErr
+}
+
+func (t *readWriteTransaction) replay(ctx context.Context) error {
+	for _, s := range t.statements {
+		if err := s.retry(ctx, t.tx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// verifyRetry returns nil if a statement returned the same error in the
+// retry as in the aborted transaction, the error if the retry was aborted
+// too, and ErrAbortedDueToConcurrentModification otherwise.
+func verifyRetry(err, original error) error {
+	if spanner.ErrCode(err) == codes.Aborted {
+		return err
+	}
+	if spanner.ErrCode(err) != spanner.ErrCode(original) || (err == nil) != (original == nil) {
+		return ErrAbortedDueToConcurrentModification
+	}
+	return nil
+}
+
+type retriableUpdate struct {
+	stmt  spanner.Statement
+	count int64
+	err   error
+}
+
+func (s *retriableUpdate) retry(ctx context.Context, tx stmtBasedTransaction) error {
+	count, err := tx.Update(ctx, s.stmt)
+	if err := verifyRetry(err, s.err); err != nil {
+		return err
+	}
+	if count != s.count {
+		return ErrAbortedDueToConcurrentModification
+	}
+	return nil
+}
+
+type retriableBatchUpdate struct {
+	stmts  []spanner.Statement
+	counts []int64
+	err    error
+}
+
+func (s *retriableBatchUpdate) retry(ctx context.Context, tx stmtBasedTransaction) error {
+	counts, err := tx.BatchUpdate(ctx, s.stmts)
+	if err := verifyRetry(err, s.err); err != nil {
+		return err
+	}
+	if len(counts) != len(s.counts) {
+		return ErrAbortedDueToConcurrentModification
+	}
+	for i := range counts {
+		if counts[i] != s.counts[i] {
+			return ErrAbortedDueToConcurrentModification
+		}
+	}
+	return nil
+}
+
+type retriableBufferWrite []*spanner.Mutation
+
+func (ms retriableBufferWrite) retry(ctx context.Context, tx stmtBasedTransaction) error {
+	return tx.BufferWrite(ms)
+}
+
+// retriableQuery is a query in a readWriteTransaction which keeps a checksum
+// of the rows read so far. When the transaction is retried, the query is
+// executed again and the same number of rows is read and compared by their
+// checksum, after which the rows which have not been read yet are read from
+// the new transaction.
+type retriableQuery struct {
+	tx   *readWriteTransaction
+	ctx  context.Context
+	stmt spanner.Statement
+	it   rowIterator
+
+	checksum hash.Hash
+	rows     int
+	// done is set when the query has read all rows, and err when it failed.
+	done    bool
+	err     error
+	stopped bool
+}
+
+func (q *retriableQuery) Next() (*spanner.Row, error) {
+	for {
+		row, err := q.it.Next()
+		if q.tx.shouldRetry(err) {
+			if err := q.tx.retry(q.ctx, err); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err == iterator.Done {
+			q.done = true
+			return nil, err
+		}
+		if err != nil {
+			q.err = err
+			return nil, err
+		}
+		if err := addRowChecksum(q.checksum, row); err != nil {
+			return nil, err
+		}
+		q.rows++
+		return row, nil
+	}
+}
+
+func (q *retriableQuery) Stop() {
+	q.stopped = true
+	q.it.Stop()
+}
+
+func (q *retriableQuery) Metadata() *sppb.ResultSetMetadata {
+	return q.it.Metadata()
+}
+
+func (q *retriableQuery) retry(ctx context.Context, tx stmtBasedTransaction) error {
+	it := tx.Query(ctx, q.stmt)
+	if err := q.verify(it); err != nil {
+		it.Stop()
+		return err
+	}
+	if q.stopped {
+		it.Stop()
+	}
+	q.it = it
+	return nil
+}
+
+func (q *retriableQuery) verify(it rowIterator) error {
+	checksum := sha256.New()
+	for i := 0; i < q.rows; i++ {
+		row, err := it.Next()
+		if err != nil {
+			if err := verifyRetry(err, nil); err != nil {
+				return err
+			}
+			return ErrAbortedDueToConcurrentModification
+		}
+		if err := addRowChecksum(checksum, row); err != nil {
+			return err
+		}
+	}
+	if !bytes.Equal(checksum.Sum(nil), q.checksum.Sum(nil)) {
+		return ErrAbortedDueToConcurrentModification
+	}
+	if !q.done && q.err == nil {
+		return nil
+	}
+	// The end of the rows or the error must be at the same row as well.
+	_, err := it.Next()
+	if q.done {
+		if err == iterator.Done {
+			return nil
+		}
+		if err := verifyRetry(err, nil); err != nil {
+			return err
+		}
+		return ErrAbortedDueToConcurrentModification
+	}
+	return verifyRetry(err, q.err)
+}
+
+// addRowChecksum adds the values of row to checksum.
+func addRowChecksum(checksum hash.Hash, row *spanner.Row) error {
+	opts := proto.MarshalOptions{Deterministic: true}
+	for i := 0; i < row.Size(); i++ {
+		var col spanner.GenericColumnValue
+		if err := row.Column(i, &col); err != nil {
+			return err
+		}
+		b, err := opts.Marshal(col.Value)
+		if err != nil {
+			return err
+		}
+		checksum.Write(b)
+	}
+	return nil
+}
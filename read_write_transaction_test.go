@@ -0,0 +1,331 @@
+package spannerdriver
+
+import (
+	"context"
+	"crypto/sha256"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"google.golang.org/api/iterator"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	sppb "google.golang.org/genproto/googleapis/spanner/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+func TestVerifyRetry(t *testing.T) {
+	aborted := status.Error(codes.Aborted, "aborted")
+	notFound := status.Error(codes.NotFound, "not found")
+	tests := []struct {
+		err, original, want error
+	}{
+		{nil, nil, nil},
+		{notFound, status.Error(codes.NotFound, "other message"), nil},
+		{aborted, nil, aborted},
+		{aborted, notFound, aborted},
+		{notFound, nil, ErrAbortedDueToConcurrentModification},
+		{nil, notFound, ErrAbortedDueToConcurrentModification},
+		{status.Error(codes.AlreadyExists, "already exists"), notFound, ErrAbortedDueToConcurrentModification},
+	}
+	for _, tt := range tests {
+		if got := verifyRetry(tt.err, tt.original); got != tt.want {
+			t.Errorf("verifyRetry(%v, %v): expected %v, got %v", tt.err, tt.original, tt.want, got)
+		}
+	}
+}
+
+func TestAddRowChecksum(t *testing.T) {
+	checksum := func(rows ...*spanner.Row) string {
+		h := sha256.New()
+		for _, row := range rows {
+			if err := addRowChecksum(h, row); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return string(h.Sum(nil))
+	}
+	row := func(id int64, name interface{}) *spanner.Row {
+		r, err := spanner.NewRow([]string{"Id", "Name"}, []interface{}{id, name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+
+	if checksum(row(1, "a"), row(2, "b")) != checksum(row(1, "a"), row(2, "b")) {
+		t.Error("expected equal rows to have equal checksums")
+	}
+	for _, rows := range [][]*spanner.Row{
+		{row(1, "a"), row(2, "c")},
+		{row(2, "b"), row(1, "a")},
+		{row(1, "a")},
+		{row(1, "a"), row(2, spanner.NullString{})},
+	} {
+		if checksum(rows...) == checksum(row(1, "a"), row(2, "b")) {
+			t.Errorf("expected different checksum for %v", rows)
+		}
+	}
+}
+
+func TestSetRetryAbortsInternally(t *testing.T) {
+	ctx := context.Background()
+	c := newTestConn()
+
+	if _, err := c.ExecContext(ctx, "SET RETRY_ABORTS_INTERNALLY = TRUE", nil); err != nil {
+		t.Fatal(err)
+	}
+	if !c.retryAborts {
+		t.Error("expected aborted transactions to be retried")
+	}
+	if _, err := c.ExecContext(ctx, "SET RETRY_ABORTS_INTERNALLY = maybe", nil); err == nil {
+		t.Error("expected error for invalid value, got nil")
+	}
+	c.rwTx = &readWriteTransaction{}
+	if _, err := c.ExecContext(ctx, "SET RETRY_ABORTS_INTERNALLY = FALSE", nil); err == nil {
+		t.Error("expected error in a transaction, got nil")
+	}
+	c.rwTx = nil
+
+	if err := c.ResetSession(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if c.retryAborts {
+		t.Error("expected ResetSession to restore the default")
+	}
+}
+
+// fakeDatabase begins fakeTransactions, whose results are returned by its
+// functions for the attempt of the transaction.
+type fakeDatabase struct {
+	// abortedCommits is the number of commits which are aborted, with the
+	// retry delay in the error if it is set.
+	abortedCommits int
+	retryDelay     time.Duration
+	// commitErr is returned by the commits which are not aborted.
+	commitErr error
+	// batchErr is returned by BatchUpdate for the last statement of a batch.
+	batchErr    error
+	updateCount func(attempt int) int64
+	queryRows   func(attempt int) []*spanner.Row
+
+	transactions []*fakeTransaction
+}
+
+func (db *fakeDatabase) begin(ctx context.Context) (stmtBasedTransaction, error) {
+	tx := &fakeTransaction{db: db, attempt: len(db.transactions)}
+	db.transactions = append(db.transactions, tx)
+	return tx, nil
+}
+
+// open returns the number of transactions which have not ended, whose
+// sessions have not been returned to the session pool.
+func (db *fakeDatabase) open() int {
+	n := 0
+	for _, tx := range db.transactions {
+		if !tx.ended {
+			n++
+		}
+	}
+	return n
+}
+
+type fakeTransaction struct {
+	db        *fakeDatabase
+	attempt   int
+	ended     bool
+	committed bool
+}
+
+func (tx *fakeTransaction) Update(ctx context.Context, stmt spanner.Statement) (int64, error) {
+	return tx.db.updateCount(tx.attempt), nil
+}
+
+func (tx *fakeTransaction) BatchUpdate(ctx context.Context, stmts []spanner.Statement) ([]int64, error) {
+	if tx.db.batchErr != nil {
+		counts := make([]int64, len(stmts)-1)
+		for i := range counts {
+			counts[i] = tx.db.updateCount(tx.attempt)
+		}
+		return counts, tx.db.batchErr
+	}
+	return []int64{tx.db.updateCount(tx.attempt)}, nil
+}
+
+func (tx *fakeTransaction) Query(ctx context.Context, stmt spanner.Statement) rowIterator {
+	return &fakeRowIterator{rows: tx.db.queryRows(tx.attempt)}
+}
+
+func (tx *fakeTransaction) BufferWrite(ms []*spanner.Mutation) error {
+	return nil
+}
+
+func (tx *fakeTransaction) Commit(ctx context.Context) (time.Time, error) {
+	tx.ended = true
+	if tx.db.abortedCommits > 0 {
+		tx.db.abortedCommits--
+		s := status.New(codes.Aborted, "aborted")
+		if tx.db.retryDelay > 0 {
+			s, _ = s.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(tx.db.retryDelay)})
+		}
+		return time.Time{}, spanner.ToSpannerError(s.Err())
+	}
+	if tx.db.commitErr != nil {
+		return time.Time{}, tx.db.commitErr
+	}
+	tx.committed = true
+	return time.Unix(1, 0), nil
+}
+
+func (tx *fakeTransaction) Rollback(ctx context.Context) {
+	tx.ended = true
+}
+
+type fakeRowIterator struct {
+	rows []*spanner.Row
+	// err is returned after the rows if it is set.
+	err error
+}
+
+func (it *fakeRowIterator) Next() (*spanner.Row, error) {
+	if len(it.rows) == 0 && it.err != nil {
+		return nil, it.err
+	}
+	if len(it.rows) == 0 {
+		return nil, iterator.Done
+	}
+	row := it.rows[0]
+	it.rows = it.rows[1:]
+	return row, nil
+}
+
+func (it *fakeRowIterator) Stop() {}
+
+func (it *fakeRowIterator) Metadata() *sppb.ResultSetMetadata {
+	return nil
+}
+
+func newFakeReadWriteTransaction(t *testing.T, db *fakeDatabase) *readWriteTransaction {
+	tx, err := db.begin(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &readWriteTransaction{tx: tx, retryAborts: true, begin: db.begin}
+}
+
+func TestRetryAbortedTransaction(t *testing.T) {
+	ctx := context.Background()
+	row := func(id string) *spanner.Row {
+		r, err := spanner.NewRow([]string{"Id"}, []interface{}{id})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+	tests := []struct {
+		name        string
+		updateCount func(attempt int) int64
+		queryRows   func(attempt int) []*spanner.Row
+		wantErr     error
+	}{
+		{
+			name:        "same results",
+			updateCount: func(int) int64 { return 1 },
+			queryRows:   func(int) []*spanner.Row { return []*spanner.Row{row("userId1")} },
+		},
+		{
+			name:        "different update count",
+			updateCount: func(attempt int) int64 { return int64(attempt + 1) },
+			queryRows:   func(int) []*spanner.Row { return []*spanner.Row{row("userId1")} },
+			wantErr:     ErrAbortedDueToConcurrentModification,
+		},
+		{
+			name:        "different rows",
+			updateCount: func(int) int64 { return 1 },
+			queryRows: func(attempt int) []*spanner.Row {
+				if attempt == 0 {
+					return []*spanner.Row{row("userId1")}
+				}
+				return []*spanner.Row{row("userId2")}
+			},
+			wantErr: ErrAbortedDueToConcurrentModification,
+		},
+		{
+			name:        "more rows",
+			updateCount: func(int) int64 { return 1 },
+			queryRows: func(attempt int) []*spanner.Row {
+				if attempt == 0 {
+					return []*spanner.Row{row("userId1")}
+				}
+				return []*spanner.Row{row("userId1"), row("userId2")}
+			},
+			wantErr: ErrAbortedDueToConcurrentModification,
+		},
+	}
+	for _, tt := range tests {
+		db := &fakeDatabase{abortedCommits: 1, updateCount: tt.updateCount, queryRows: tt.queryRows}
+		tx := newFakeReadWriteTransaction(t, db)
+
+		it := tx.Query(ctx, spanner.NewStatement("SELECT Id FROM test"))
+		for {
+			if _, err := it.Next(); err == iterator.Done {
+				break
+			} else if err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+		}
+		it.Stop()
+		if _, err := tx.Update(ctx, spanner.NewStatement("UPDATE test SET Value = true WHERE true")); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+
+		_, err := tx.Commit(ctx)
+		if err != tt.wantErr {
+			t.Errorf("%s: expected error %v, got %v", tt.name, tt.wantErr, err)
+		}
+		if len(db.transactions) != 2 {
+			t.Errorf("%s: expected the transaction to be retried once, got %d transactions", tt.name, len(db.transactions))
+		}
+		if committed := db.transactions[len(db.transactions)-1].committed; committed != (tt.wantErr == nil) {
+			t.Errorf("%s: expected the retry to be committed: %v, got %v", tt.name, tt.wantErr == nil, committed)
+		}
+		if n := db.open(); n != 0 {
+			t.Errorf("%s: expected every transaction to be ended, got %d open transactions", tt.name, n)
+		}
+	}
+}
+
+func TestRetryAbortedTransactionGivesUp(t *testing.T) {
+	ctx := context.Background()
+	db := &fakeDatabase{updateCount: func(int) int64 { return 1 }, retryDelay: time.Millisecond}
+	tx := newFakeReadWriteTransaction(t, db)
+	if _, err := tx.Update(ctx, spanner.NewStatement("UPDATE test SET Value = true WHERE true")); err != nil {
+		t.Fatal(err)
+	}
+	// Every commit is aborted, and the transaction has two retries left.
+	db.abortedCommits = maxAbortRetries + 1
+	tx.retries = maxAbortRetries - 2
+	if _, err := tx.Commit(ctx); spanner.ErrCode(err) != codes.Aborted {
+		t.Fatalf("expected the abort, got %v", err)
+	}
+	if n := len(db.transactions); n != 3 {
+		t.Errorf("expected 3 transactions, got %d", n)
+	}
+	if n := db.open(); n != 0 {
+		t.Errorf("expected every transaction to be ended, got %d open transactions", n)
+	}
+}
+
+func TestRetryAbortedTransactionCanceled(t *testing.T) {
+	db := &fakeDatabase{abortedCommits: 1, updateCount: func(int) int64 { return 1 }}
+	tx := newFakeReadWriteTransaction(t, db)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := tx.Commit(ctx); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if n := db.open(); n != 0 {
+		t.Errorf("expected every transaction to be ended, got %d open transactions", n)
+	}
+}
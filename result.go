@@ -1,9 +1,36 @@
 package spannerdriver
 
-import "github.com/pkg/errors"
+import (
+	"database/sql/driver"
+
+	"github.com/pkg/errors"
+)
+
+// SpannerResult is the result of a statement executed by the driver.
+// The result of a DML batch also has the row counts of its statements.
+type SpannerResult interface {
+	driver.Result
+
+	// BatchRowsAffected returns the number of rows affected by each
+	// statement of a DML batch.
+	BatchRowsAffected() ([]int64, error)
+}
 
 type spannerResult struct {
 	rowsAffected int64
+	// batchRowsAffected is set for the result of a DML batch.
+	batchRowsAffected []int64
+}
+
+func newBatchResult(batchRowsAffected []int64) *spannerResult {
+	r := &spannerResult{batchRowsAffected: batchRowsAffected}
+	if r.batchRowsAffected == nil {
+		r.batchRowsAffected = []int64{}
+	}
+	for _, n := range batchRowsAffected {
+		r.rowsAffected += n
+	}
+	return r
 }
 
 // LastInsertId implements database/sql/driver.Result interface.
@@ -15,3 +42,11 @@ func (r *spannerResult) LastInsertId() (int64, error) {
 func (r *spannerResult) RowsAffected() (int64, error) {
 	return r.rowsAffected, nil
 }
+
+// BatchRowsAffected implements SpannerResult interface.
+func (r *spannerResult) BatchRowsAffected() ([]int64, error) {
+	if r.batchRowsAffected == nil {
+		return nil, errors.New("not the result of a DML batch")
+	}
+	return r.batchRowsAffected, nil
+}
@@ -2,16 +2,37 @@ package spannerdriver
 
 import (
 	"database/sql/driver"
+	"reflect"
+	"testing"
 )
 
 // static interface implementation checks of mysqlStmt
 var (
-	_ driver.Result = &spannerResult{}
-	_ driver.Rows   = &spannerRows{}
-	// _ driver.RowsColumnTypeDatabaseTypeName = &spannerRows{}
-	// _ driver.RowsColumnTypeLength           = &spannerRows{}
-	// _ driver.RowsColumnTypeNullable         = &spannerRows{}
-	// _ driver.RowsColumnTypePrecisionScale   = &spannerRows{}
-	// _ driver.RowsColumnTypeScanType         = &spannerRows{}
+	_ driver.Result                         = &spannerResult{}
+	_ SpannerResult                         = &spannerResult{}
+	_ driver.Rows                           = &spannerRows{}
+	_ driver.RowsColumnTypeDatabaseTypeName = &spannerRows{}
+	_ driver.RowsColumnTypeLength           = &spannerRows{}
+	_ driver.RowsColumnTypeNullable         = &spannerRows{}
+	_ driver.RowsColumnTypePrecisionScale   = &spannerRows{}
+	_ driver.RowsColumnTypeScanType         = &spannerRows{}
 	// _ driver.RowsNextResultSet              = &spannerRows{}
 )
+
+func TestBatchResult(t *testing.T) {
+	r := newBatchResult([]int64{1, 0, 3})
+	if n, _ := r.RowsAffected(); n != 4 {
+		t.Errorf("expected 4 rows affected in total, got %d", n)
+	}
+	counts, err := r.BatchRowsAffected()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int64{1, 0, 3}; !reflect.DeepEqual(counts, want) {
+		t.Errorf("expected %v, got %v", want, counts)
+	}
+
+	if _, err := (&spannerResult{rowsAffected: 1}).BatchRowsAffected(); err == nil {
+		t.Error("expected error for the result of a single statement, got nil")
+	}
+}
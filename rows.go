@@ -1,8 +1,12 @@
 package spannerdriver
 
 import (
+	"database/sql"
 	"database/sql/driver"
+	"encoding/json"
 	"io"
+	"reflect"
+	"strings"
 	"sync"
 	"time"
 
@@ -10,12 +14,36 @@ import (
 	"github.com/pkg/errors"
 	"google.golang.org/api/iterator"
 	sppb "google.golang.org/genproto/googleapis/spanner/v1"
+	"google.golang.org/protobuf/types/known/structpb"
 )
 
+// rowIterator iterates over the rows of a query. It is implemented by
+// spannerIterator and retriableQuery.
+type rowIterator interface {
+	Next() (*spanner.Row, error)
+	Stop()
+	// Metadata returns the result set metadata, which is available after the
+	// first call to Next.
+	Metadata() *sppb.ResultSetMetadata
+}
+
+// spannerIterator is the rowIterator of a spanner.RowIterator.
+type spannerIterator struct {
+	*spanner.RowIterator
+}
+
+func (it spannerIterator) Metadata() *sppb.ResultSetMetadata {
+	return it.RowIterator.Metadata
+}
+
 type spannerRows struct {
-	it *spanner.RowIterator
+	// conn is the connection of the query, which is marked as bad if the
+	// query fails because of its session or client.
+	conn *spannerConn
+	it   rowIterator
 
 	colsOnce sync.Once
+	cols     []string
 	done     bool
 
 	dirtyRow   *spanner.Row
@@ -23,15 +51,17 @@ type spannerRows struct {
 }
 
 // Columns implements database/sql/driver.Rows interface.
+// The column names are read from the result set metadata,
+// so they are available even if the query returned no rows.
 func (r *spannerRows) Columns() []string {
-	if r.dirtyRow != nil {
-		return r.dirtyRow.ColumnNames()
-	}
-	if r.currentRow != nil {
-		return r.currentRow.ColumnNames()
-	}
-
-	return []string{}
+	r.colsOnce.Do(func() {
+		fields := r.fields()
+		r.cols = make([]string, len(fields))
+		for i, f := range fields {
+			r.cols[i] = f.Name
+		}
+	})
+	return r.cols
 }
 
 // Close implements database/sql/driver.Rows interface.
@@ -60,7 +90,10 @@ func (r *spannerRows) Next(dest []driver.Value) error {
 	}
 	if err != nil {
 		errLog.Print(err)
-		return err
+		if r.conn == nil {
+			return wrapError(err)
+		}
+		return r.conn.markBadConn(err)
 	}
 	return r.readRow(dest)
 }
@@ -70,93 +103,343 @@ func (r *spannerRows) readRow(dest []driver.Value) error {
 		var col spanner.GenericColumnValue
 		if err := r.currentRow.Column(i, &col); err != nil {
 			return err
-			dest[i] = col
 		}
 
-		switch col.Type.Code {
-		case sppb.TypeCode_BOOL:
-			var v spanner.NullBool
-			if err := col.Decode(&v); err != nil {
-				return err
-			}
-			dest[i] = v.Bool
-		case sppb.TypeCode_INT64:
-			var v spanner.NullInt64
-			if err := col.Decode(&v); err != nil {
-				return err
-			}
-			dest[i] = v.Int64
-		case sppb.TypeCode_FLOAT64:
-			var v spanner.NullFloat64
-			if err := col.Decode(&v); err != nil {
-				return err
+		v, err := decodeColumn(col)
+		if err != nil {
+			return err
+		}
+		dest[i] = v
+	}
+	return nil
+}
+
+func decodeColumn(col spanner.GenericColumnValue) (driver.Value, error) {
+	switch col.Type.Code {
+	case sppb.TypeCode_BOOL:
+		var v spanner.NullBool
+		if err := col.Decode(&v); err != nil || !v.Valid {
+			return nil, err
+		}
+		return v.Bool, nil
+	case sppb.TypeCode_INT64:
+		var v spanner.NullInt64
+		if err := col.Decode(&v); err != nil || !v.Valid {
+			return nil, err
+		}
+		return v.Int64, nil
+	case sppb.TypeCode_FLOAT64:
+		var v spanner.NullFloat64
+		if err := col.Decode(&v); err != nil || !v.Valid {
+			return nil, err
+		}
+		return v.Float64, nil
+	case sppb.TypeCode_TIMESTAMP:
+		var v spanner.NullTime
+		if err := col.Decode(&v); err != nil || !v.Valid {
+			return nil, err
+		}
+		return v.Time, nil
+	case sppb.TypeCode_DATE:
+		var v spanner.NullDate
+		if err := col.Decode(&v); err != nil || !v.Valid {
+			return nil, err
+		}
+		return v.Date.In(time.Local), nil // TODO(jbd): Add note about this.
+	case sppb.TypeCode_STRING:
+		var v spanner.NullString
+		if err := col.Decode(&v); err != nil || !v.Valid {
+			return nil, err
+		}
+		return v.StringVal, nil
+	case sppb.TypeCode_BYTES:
+		var v []byte
+		if err := col.Decode(&v); err != nil || v == nil {
+			return nil, err
+		}
+		return v, nil
+	case sppb.TypeCode_NUMERIC:
+		var v spanner.NullNumeric
+		if err := col.Decode(&v); err != nil || !v.Valid {
+			return nil, err
+		}
+		// NUMERIC is returned in its decimal string form so that it can be
+		// scanned into a string or NullNumeric without loss of precision.
+		return spanner.NumericString(&v.Numeric), nil
+	case sppb.TypeCode_JSON:
+		if isNull(col.Value) {
+			return nil, nil
+		}
+		// JSON is returned as the raw document sent by Spanner instead of
+		// decoding it with spanner.NullJSON, which loses number precision.
+		return []byte(col.Value.GetStringValue()), nil
+	case sppb.TypeCode_STRUCT:
+		if isNull(col.Value) {
+			return nil, nil
+		}
+		return decodeStruct(col.Type.StructType, col.Value.GetListValue())
+	case sppb.TypeCode_ARRAY:
+		return decodeArray(col)
+	default:
+		return nil, errors.Errorf("unsupported type: %s", col.Type.Code)
+	}
+}
+
+// decodeStruct decodes a STRUCT value into a *spanner.Row, which can be
+// scanned into a Go struct or a map with the Struct scanner.
+func decodeStruct(t *sppb.StructType, lv *structpb.ListValue) (*spanner.Row, error) {
+	fields := t.GetFields()
+	if len(fields) != len(lv.GetValues()) {
+		return nil, errors.Errorf("STRUCT has %d fields but %d values", len(fields), len(lv.GetValues()))
+	}
+	names := make([]string, len(fields))
+	values := make([]interface{}, len(fields))
+	for i, f := range fields {
+		names[i] = f.Name
+		values[i] = spanner.GenericColumnValue{Type: f.Type, Value: lv.Values[i]}
+	}
+	return spanner.NewRow(names, values)
+}
+
+func isNull(v *structpb.Value) bool {
+	_, ok := v.GetKind().(*structpb.Value_NullValue)
+	return ok
+}
+
+// decodeArray decodes an ARRAY column into a slice of nullable element values,
+// so that NULL elements are preserved. A NULL array is returned as nil.
+func decodeArray(col spanner.GenericColumnValue) (driver.Value, error) {
+	switch col.Type.ArrayElementType.GetCode() {
+	case sppb.TypeCode_BOOL:
+		var a []spanner.NullBool
+		if err := col.Decode(&a); err != nil || a == nil {
+			return nil, err
+		}
+		return a, nil
+	case sppb.TypeCode_INT64:
+		var a []spanner.NullInt64
+		if err := col.Decode(&a); err != nil || a == nil {
+			return nil, err
+		}
+		return a, nil
+	case sppb.TypeCode_FLOAT64:
+		var a []spanner.NullFloat64
+		if err := col.Decode(&a); err != nil || a == nil {
+			return nil, err
+		}
+		return a, nil
+	case sppb.TypeCode_STRING:
+		var a []spanner.NullString
+		if err := col.Decode(&a); err != nil || a == nil {
+			return nil, err
+		}
+		return a, nil
+	case sppb.TypeCode_BYTES:
+		var a [][]byte
+		if err := col.Decode(&a); err != nil || a == nil {
+			return nil, err
+		}
+		return a, nil
+	case sppb.TypeCode_DATE:
+		var a []spanner.NullDate
+		if err := col.Decode(&a); err != nil || a == nil {
+			return nil, err
+		}
+		return a, nil
+	case sppb.TypeCode_TIMESTAMP:
+		var a []spanner.NullTime
+		if err := col.Decode(&a); err != nil || a == nil {
+			return nil, err
+		}
+		return a, nil
+	case sppb.TypeCode_NUMERIC:
+		var a []spanner.NullNumeric
+		if err := col.Decode(&a); err != nil || a == nil {
+			return nil, err
+		}
+		return a, nil
+	case sppb.TypeCode_JSON:
+		if isNull(col.Value) {
+			return nil, nil
+		}
+		// The elements are returned as raw documents for the same reason as
+		// JSON columns, with nil for NULL elements.
+		values := col.Value.GetListValue().GetValues()
+		a := make([]json.RawMessage, len(values))
+		for i, v := range values {
+			if !isNull(v) {
+				a[i] = json.RawMessage(v.GetStringValue())
 			}
-			dest[i] = v.Float64
-		case sppb.TypeCode_TIMESTAMP:
-			var v spanner.NullTime
-			if err := col.Decode(&v); err != nil {
-				return err
+		}
+		return a, nil
+	case sppb.TypeCode_STRUCT:
+		if isNull(col.Value) {
+			return nil, nil
+		}
+		values := col.Value.GetListValue().GetValues()
+		a := make([]*spanner.Row, len(values))
+		for i, v := range values {
+			if isNull(v) {
+				continue
 			}
-			dest[i] = v.Time
-		case sppb.TypeCode_DATE:
-			var v spanner.NullDate
-			if err := col.Decode(&v); err != nil {
-				return err
+			row, err := decodeStruct(col.Type.ArrayElementType.StructType, v.GetListValue())
+			if err != nil {
+				return nil, err
 			}
-			if v.IsNull() {
-				dest[i] = v.Date // typed nil
+			a[i] = row
+		}
+		return a, nil
+	default:
+		return nil, errors.Errorf("unsupported array element type: %s", col.Type.ArrayElementType.GetCode())
+	}
+}
+
+// ColumnTypeDatabaseTypeName implements database/sql/driver.RowsColumnTypeDatabaseTypeName interface.
+func (r *spannerRows) ColumnTypeDatabaseTypeName(index int) string {
+	return typeName(r.columnType(index))
+}
+
+// ColumnTypeLength implements database/sql/driver.RowsColumnTypeLength interface.
+// The result set metadata does not contain the length of STRING and BYTES columns,
+// so the length is always reported as unknown.
+func (r *spannerRows) ColumnTypeLength(index int) (length int64, ok bool) {
+	return 0, false
+}
+
+// ColumnTypeNullable implements database/sql/driver.RowsColumnTypeNullable interface.
+// The result set metadata does not contain the nullability of columns,
+// so it is always reported as unknown.
+func (r *spannerRows) ColumnTypeNullable(index int) (nullable, ok bool) {
+	return false, false
+}
+
+// ColumnTypePrecisionScale implements database/sql/driver.RowsColumnTypePrecisionScale interface.
+func (r *spannerRows) ColumnTypePrecisionScale(index int) (precision, scale int64, ok bool) {
+	if r.columnType(index).GetCode() == sppb.TypeCode_NUMERIC {
+		return 38, 9, true
+	}
+	return 0, 0, false
+}
+
+// ColumnTypeScanType implements database/sql/driver.RowsColumnTypeScanType interface.
+func (r *spannerRows) ColumnTypeScanType(index int) reflect.Type {
+	return scanType(r.columnType(index))
+}
+
+// fields returns the columns of the result set metadata,
+// which is available after the first call to rowIterator.Next.
+func (r *spannerRows) fields() []*sppb.StructType_Field {
+	if r.it == nil {
+		return nil
+	}
+	return r.it.Metadata().GetRowType().GetFields()
+}
+
+func (r *spannerRows) columnType(index int) *sppb.Type {
+	fields := r.fields()
+	if index < 0 || index >= len(fields) {
+		return nil
+	}
+	return fields[index].Type
+}
+
+// typeName returns the GoogleSQL name of t, e.g. ARRAY<STRING> or STRUCT<Name STRING>.
+func typeName(t *sppb.Type) string {
+	switch t.GetCode() {
+	case sppb.TypeCode_TYPE_CODE_UNSPECIFIED:
+		return ""
+	case sppb.TypeCode_ARRAY:
+		return "ARRAY<" + typeName(t.ArrayElementType) + ">"
+	case sppb.TypeCode_STRUCT:
+		fields := make([]string, len(t.GetStructType().GetFields()))
+		for i, f := range t.GetStructType().GetFields() {
+			if f.Name == "" {
+				fields[i] = typeName(f.Type)
 			} else {
-				dest[i] = v.Date.In(time.Local) // TODO(jbd): Add note about this.
-			}
-		case sppb.TypeCode_STRING:
-			var v spanner.NullString
-			if err := col.Decode(&v); err != nil {
-				return err
-			}
-			dest[i] = v.StringVal
-		case sppb.TypeCode_BYTES:
-			var v []byte
-			if err := col.Decode(&v); err != nil {
-				return err
+				fields[i] = f.Name + " " + typeName(f.Type)
 			}
-			dest[i] = v
-		case sppb.TypeCode_ARRAY:
-			return errors.Errorf("unsupported type: %s", col.Type.Code)
-		case sppb.TypeCode_STRUCT:
-			return errors.Errorf("unsupported type: %s", col.Type.Code)
-		case sppb.TypeCode_NUMERIC:
-			return errors.Errorf("unsupported type: %s", col.Type.Code)
-		case sppb.TypeCode_JSON:
-			return errors.Errorf("unsupported type: %s", col.Type.Code)
-		default:
-			return errors.Errorf("unsupported type: %s", col.Type.Code)
 		}
+		return "STRUCT<" + strings.Join(fields, ", ") + ">"
 	}
-	return nil
+	return t.GetCode().String()
+}
+
+var (
+	scanTypeNullBool    = reflect.TypeOf(sql.NullBool{})
+	scanTypeNullInt64   = reflect.TypeOf(sql.NullInt64{})
+	scanTypeNullFloat64 = reflect.TypeOf(sql.NullFloat64{})
+	scanTypeNullString  = reflect.TypeOf(sql.NullString{})
+	scanTypeNullTime    = reflect.TypeOf(sql.NullTime{})
+	scanTypeBytes       = reflect.TypeOf([]byte{})
+	scanTypeNullNumeric = reflect.TypeOf(NullNumeric{})
+	scanTypeRawJSON     = reflect.TypeOf(&json.RawMessage{})
+	scanTypeRow         = reflect.TypeOf(&spanner.Row{})
+	scanTypeUnknown     = reflect.TypeOf(new(interface{})).Elem()
+)
+
+// scanType returns the Go type that a column of type t can be scanned into.
+// Nullable types are used because any column of a query result may be NULL.
+func scanType(t *sppb.Type) reflect.Type {
+	switch t.GetCode() {
+	case sppb.TypeCode_BOOL:
+		return scanTypeNullBool
+	case sppb.TypeCode_INT64:
+		return scanTypeNullInt64
+	case sppb.TypeCode_FLOAT64:
+		return scanTypeNullFloat64
+	case sppb.TypeCode_STRING:
+		return scanTypeNullString
+	case sppb.TypeCode_TIMESTAMP, sppb.TypeCode_DATE:
+		return scanTypeNullTime
+	case sppb.TypeCode_BYTES:
+		return scanTypeBytes
+	case sppb.TypeCode_NUMERIC:
+		return scanTypeNullNumeric
+	case sppb.TypeCode_JSON:
+		return scanTypeRawJSON
+	case sppb.TypeCode_STRUCT:
+		return scanTypeRow
+	case sppb.TypeCode_ARRAY:
+		return arrayScanType(t.ArrayElementType)
+	}
+	return scanTypeUnknown
 }
 
-// // ColumnTypeDatabaseTypeName implements database/sql/driver.RowsColumnTypeDatabaseTypeName interface.
-// func (r *spannerRows) ColumnTypeDatabaseTypeName(index int) string {
-// 	return ""
-// }
-
-//
-// // ColumnTypeLength implements database/sql/driver.RowsColumnTypeLength interface.
-// func (r *spannerRows) ColumnTypeLength(index int) (length int64, ok bool) {
-// 	return int64(0), false
-// }
-//
-// // ColumnTypeNullable implements database/sql/driver.RowsColumnTypeNullable interface.
-// func (r *spannerRows) ColumnTypeNullable(index int) (nullable, ok bool) {
-// 	return false, false
-// }
-//
-// // ColumnTypePrecisionScale implements database/sql/driver.RowsColumnTypePrecisionScale interface.
-// func (r *spannerRows) ColumnTypePrecisionScale(index int) (presicion, scale int64, ok bool) {
-// 	return int64(0), int64(0), false
-// }
-//
-// // ColumnTypeScanType implements database/sql/driver.RowsColumnTypeScanType interface.
-// func (r *spannerRows) ColumnTypeScanType(index int) reflect.Type {
-// 	return reflect.TypeOf("")
-// }
+var (
+	scanTypeNullBoolArray    = reflect.TypeOf(NullBoolArray{})
+	scanTypeNullInt64Array   = reflect.TypeOf(NullInt64Array{})
+	scanTypeNullFloat64Array = reflect.TypeOf(NullFloat64Array{})
+	scanTypeNullStringArray  = reflect.TypeOf(NullStringArray{})
+	scanTypeBytesArray       = reflect.TypeOf(BytesArray{})
+	scanTypeNullDateArray    = reflect.TypeOf(NullDateArray{})
+	scanTypeNullTimeArray    = reflect.TypeOf(NullTimeArray{})
+	scanTypeNullNumericArray = reflect.TypeOf(NullNumericArray{})
+	scanTypeNullJSONArray    = reflect.TypeOf(NullJSONArray{})
+	scanTypeRowArray         = reflect.TypeOf(RowArray{})
+)
+
+func arrayScanType(elem *sppb.Type) reflect.Type {
+	switch elem.GetCode() {
+	case sppb.TypeCode_BOOL:
+		return scanTypeNullBoolArray
+	case sppb.TypeCode_INT64:
+		return scanTypeNullInt64Array
+	case sppb.TypeCode_FLOAT64:
+		return scanTypeNullFloat64Array
+	case sppb.TypeCode_STRING:
+		return scanTypeNullStringArray
+	case sppb.TypeCode_BYTES:
+		return scanTypeBytesArray
+	case sppb.TypeCode_DATE:
+		return scanTypeNullDateArray
+	case sppb.TypeCode_TIMESTAMP:
+		return scanTypeNullTimeArray
+	case sppb.TypeCode_NUMERIC:
+		return scanTypeNullNumericArray
+	case sppb.TypeCode_JSON:
+		return scanTypeNullJSONArray
+	case sppb.TypeCode_STRUCT:
+		return scanTypeRowArray
+	}
+	return scanTypeUnknown
+}
@@ -0,0 +1,236 @@
+package spannerdriver
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"cloud.google.com/go/spanner"
+	sppb "google.golang.org/genproto/googleapis/spanner/v1"
+)
+
+func newTestRows(t *testing.T, names []string, values []interface{}) *spannerRows {
+	t.Helper()
+	row, err := spanner.NewRow(names, values)
+	if err != nil {
+		t.Fatalf("error creating row: %+v", err)
+	}
+	return &spannerRows{currentRow: row}
+}
+
+func TestReadRowArray(t *testing.T) {
+	date := civil.Date{Year: 2021, Month: 11, Day: 4}
+	tests := []struct {
+		name  string
+		value interface{}
+		want  driver.Value
+	}{
+		{"bool", []spanner.NullBool{{Bool: true, Valid: true}, {}}, []spanner.NullBool{{Bool: true, Valid: true}, {}}},
+		{"int64", []spanner.NullInt64{{Int64: 1, Valid: true}, {}}, []spanner.NullInt64{{Int64: 1, Valid: true}, {}}},
+		{"float64", []float64{1.5}, []spanner.NullFloat64{{Float64: 1.5, Valid: true}}},
+		{"string", []string{"a", "b"}, []spanner.NullString{{StringVal: "a", Valid: true}, {StringVal: "b", Valid: true}}},
+		{"bytes", [][]byte{[]byte("a"), nil}, [][]byte{[]byte("a"), nil}},
+		{"date", []civil.Date{date}, []spanner.NullDate{{Date: date, Valid: true}}},
+		{"json", []spanner.NullJSON{{Value: json.RawMessage(`{"n":9007199254740993}`), Valid: true}, {}}, []json.RawMessage{json.RawMessage(`{"n":9007199254740993}`), nil}},
+		{"empty", []string{}, []spanner.NullString{}},
+		{"null", []string(nil), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRows(t, []string{"col"}, []interface{}{tt.value})
+			dest := make([]driver.Value, 1)
+			if err := r.readRow(dest); err != nil {
+				t.Fatalf("readRow returned error: %+v", err)
+			}
+			if !reflect.DeepEqual(dest[0], tt.want) {
+				t.Errorf("expected %#v, got %#v", tt.want, dest[0])
+			}
+		})
+	}
+}
+
+func TestReadRowNumeric(t *testing.T) {
+	r := newTestRows(t, []string{"num", "null"}, []interface{}{big.NewRat(12345, 100), spanner.NullNumeric{}})
+	dest := make([]driver.Value, 2)
+	if err := r.readRow(dest); err != nil {
+		t.Fatalf("readRow returned error: %+v", err)
+	}
+	if want := "123.450000000"; dest[0] != want {
+		t.Errorf("expected %q, got %#v", want, dest[0])
+	}
+	if dest[1] != nil {
+		t.Errorf("expected nil, got %#v", dest[1])
+	}
+}
+
+func TestReadRowJSON(t *testing.T) {
+	r := newTestRows(t, []string{"json", "null"}, []interface{}{
+		spanner.NullJSON{Value: map[string]interface{}{"a": 1}, Valid: true},
+		spanner.NullJSON{},
+	})
+	dest := make([]driver.Value, 2)
+	if err := r.readRow(dest); err != nil {
+		t.Fatalf("readRow returned error: %+v", err)
+	}
+	if want := []byte(`{"a":1}`); !reflect.DeepEqual(dest[0], want) {
+		t.Errorf("expected %s, got %#v", want, dest[0])
+	}
+	if dest[1] != nil {
+		t.Errorf("expected nil, got %#v", dest[1])
+	}
+}
+
+func TestReadRowNull(t *testing.T) {
+	values := []interface{}{
+		spanner.NullBool{},
+		spanner.NullInt64{},
+		spanner.NullFloat64{},
+		spanner.NullTime{},
+		spanner.NullDate{},
+		spanner.NullString{},
+		[]byte(nil),
+		spanner.NullNumeric{},
+		spanner.NullJSON{},
+		[]int64(nil),
+	}
+	names := []string{"bool", "int64", "float64", "timestamp", "date", "string", "bytes", "numeric", "json", "array"}
+	r := newTestRows(t, names, values)
+	dest := make([]driver.Value, len(values))
+	if err := r.readRow(dest); err != nil {
+		t.Fatalf("readRow returned error: %+v", err)
+	}
+	for i, v := range dest {
+		if v != nil {
+			t.Errorf("%s: expected nil, got %#v", names[i], v)
+		}
+	}
+}
+
+func TestReadRowNotNull(t *testing.T) {
+	ts := time.Date(2021, 11, 4, 12, 0, 0, 0, time.UTC)
+	values := []interface{}{true, int64(1), 1.5, ts, "a", []byte{}}
+	names := []string{"bool", "int64", "float64", "timestamp", "string", "bytes"}
+	r := newTestRows(t, names, values)
+	dest := make([]driver.Value, len(values))
+	if err := r.readRow(dest); err != nil {
+		t.Fatalf("readRow returned error: %+v", err)
+	}
+	want := []driver.Value{true, int64(1), 1.5, ts, "a", []byte{}}
+	if !reflect.DeepEqual(dest, want) {
+		t.Errorf("expected %#v, got %#v", want, dest)
+	}
+}
+
+func TestReadRowStruct(t *testing.T) {
+	type item struct {
+		Name  string
+		Count int64
+	}
+	r := newTestRows(t, []string{"items"}, []interface{}{[]*item{{Name: "a", Count: 1}, nil}})
+	dest := make([]driver.Value, 1)
+	if err := r.readRow(dest); err != nil {
+		t.Fatalf("readRow returned error: %+v", err)
+	}
+	rows, ok := dest[0].([]*spanner.Row)
+	if !ok || len(rows) != 2 {
+		t.Fatalf("expected []*spanner.Row of length 2, got %#v", dest[0])
+	}
+	var got item
+	if err := rows[0].ToStruct(&got); err != nil {
+		t.Fatalf("ToStruct returned error: %+v", err)
+	}
+	if want := (item{Name: "a", Count: 1}); got != want {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	if rows[1] != nil {
+		t.Errorf("expected nil for NULL element, got %v", rows[1])
+	}
+}
+
+func TestRowsColumnTypes(t *testing.T) {
+	stringType := &sppb.Type{Code: sppb.TypeCode_STRING}
+	fields := []*sppb.StructType_Field{
+		{Name: "id", Type: &sppb.Type{Code: sppb.TypeCode_INT64}},
+		{Name: "tags", Type: &sppb.Type{Code: sppb.TypeCode_ARRAY, ArrayElementType: stringType}},
+		{Name: "price", Type: &sppb.Type{Code: sppb.TypeCode_NUMERIC}},
+		{Name: "items", Type: &sppb.Type{Code: sppb.TypeCode_ARRAY, ArrayElementType: &sppb.Type{
+			Code: sppb.TypeCode_STRUCT,
+			StructType: &sppb.StructType{Fields: []*sppb.StructType_Field{
+				{Name: "name", Type: stringType},
+				{Type: &sppb.Type{Code: sppb.TypeCode_TIMESTAMP}},
+			}},
+		}}},
+		{Name: "attrs", Type: &sppb.Type{Code: sppb.TypeCode_JSON}},
+		{Name: "data", Type: &sppb.Type{Code: sppb.TypeCode_BYTES}},
+	}
+	r := &spannerRows{it: spannerIterator{&spanner.RowIterator{Metadata: &sppb.ResultSetMetadata{
+		RowType: &sppb.StructType{Fields: fields},
+	}}}}
+
+	tests := []struct {
+		name      string
+		scanType  reflect.Type
+		precision int64
+		scale     int64
+		ok        bool
+	}{
+		{"INT64", reflect.TypeOf(sql.NullInt64{}), 0, 0, false},
+		{"ARRAY<STRING>", reflect.TypeOf(NullStringArray{}), 0, 0, false},
+		{"NUMERIC", reflect.TypeOf(NullNumeric{}), 38, 9, true},
+		{"ARRAY<STRUCT<name STRING, TIMESTAMP>>", reflect.TypeOf(RowArray{}), 0, 0, false},
+		{"JSON", reflect.TypeOf(&json.RawMessage{}), 0, 0, false},
+		{"BYTES", reflect.TypeOf([]byte{}), 0, 0, false},
+	}
+	for i, tt := range tests {
+		if got := r.ColumnTypeDatabaseTypeName(i); got != tt.name {
+			t.Errorf("column %d: expected type name %s, got %s", i, tt.name, got)
+		}
+		if got := r.ColumnTypeScanType(i); got != tt.scanType {
+			t.Errorf("column %d: expected scan type %s, got %s", i, tt.scanType, got)
+		}
+		precision, scale, ok := r.ColumnTypePrecisionScale(i)
+		if precision != tt.precision || scale != tt.scale || ok != tt.ok {
+			t.Errorf("column %d: expected precision/scale (%d, %d, %t), got (%d, %d, %t)",
+				i, tt.precision, tt.scale, tt.ok, precision, scale, ok)
+		}
+		if !canScanNull(tt.scanType) {
+			t.Errorf("column %d: expected scan type %s to accept NULL", i, tt.scanType)
+		}
+	}
+
+	if got := r.ColumnTypeDatabaseTypeName(len(fields)); got != "" {
+		t.Errorf("expected empty type name for out of range column, got %s", got)
+	}
+}
+
+func TestColumnsWithoutRows(t *testing.T) {
+	r := &spannerRows{done: true, it: spannerIterator{&spanner.RowIterator{Metadata: &sppb.ResultSetMetadata{
+		RowType: &sppb.StructType{Fields: []*sppb.StructType_Field{
+			{Name: "Id", Type: &sppb.Type{Code: sppb.TypeCode_STRING}},
+			{Name: "Value", Type: &sppb.Type{Code: sppb.TypeCode_BOOL}},
+		}},
+	}}}}
+	if want, got := []string{"Id", "Value"}, r.Columns(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	r = &spannerRows{done: true, it: spannerIterator{&spanner.RowIterator{}}}
+	if got := r.Columns(); len(got) != 0 {
+		t.Errorf("expected no columns without metadata, got %v", got)
+	}
+}
+
+// canScanNull reports whether database/sql can scan NULL into a value of type
+// t: a Scanner, a pointer, which is set to nil, or []byte.
+func canScanNull(t reflect.Type) bool {
+	dest := reflect.New(t).Interface()
+	if s, ok := dest.(sql.Scanner); ok {
+		return s.Scan(nil) == nil
+	}
+	return t.Kind() == reflect.Ptr || t == reflect.TypeOf([]byte{})
+}
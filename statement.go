@@ -2,9 +2,17 @@ package spannerdriver
 
 import (
 	"context"
+	"database/sql"
 	"database/sql/driver"
+	"encoding/json"
+	"math"
+	"math/big"
+	"reflect"
+	"time"
 
+	"cloud.google.com/go/civil"
 	"cloud.google.com/go/spanner"
+	"github.com/pkg/errors"
 	"github.com/yuemori/go-sql-driver-spanner/internal"
 )
 
@@ -31,17 +39,19 @@ func (stmt *spannerStmt) NumInput() int {
 
 // Exec implements database/sql/driver.Stmt interface.
 func (stmt *spannerStmt) Exec(args []driver.Value) (driver.Result, error) {
-	return nil, notImplementedError(stmt, "Exec")
+	return stmt.ExecContext(context.Background(), namedValues(args))
 }
 
 // Query implements database/sql/driver.Stmt interface.
 func (stmt *spannerStmt) Query(args []driver.Value) (driver.Rows, error) {
-	return nil, notImplementedError(stmt, "Query")
+	return stmt.QueryContext(context.Background(), namedValues(args))
 }
 
 // ExecContext implements database/sql/driver.StmtExecContext interface.
+// The statement is executed in the same way as spannerConn.ExecContext,
+// i.e. in the current read-write transaction or in a new one.
 func (stmt *spannerStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
-	return nil, notImplementedError(stmt, "ExecContext")
+	return stmt.conn.ExecContext(ctx, stmt.query, args)
 }
 
 // QueryContext implements database/sql/driver.StmtQueryContext interface.
@@ -50,11 +60,252 @@ func (s *spannerStmt) QueryContext(ctx context.Context, args []driver.NamedValue
 }
 
 // CheckNamedValue implements database/sql/driver.NamedValueChecker interface.
-func (stmt *spannerStmt) CheckNamedValue(*driver.NamedValue) error {
-	return notImplementedError(stmt, "CheckNamedValue")
+func (stmt *spannerStmt) CheckNamedValue(nv *driver.NamedValue) error {
+	return checkNamedValue(nv)
 }
 
+// checkNamedValue validates an argument and converts it into a value that
+// the spanner client can encode as a query parameter.
+func checkNamedValue(nv *driver.NamedValue) error {
+	v, err := convertValue(nv.Value)
+	if err != nil {
+		return err
+	}
+	nv.Value = v
+	return nil
+}
+
+func convertValue(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	// Types which the spanner client encodes as they are.
+	case nil, string, []byte, int64, bool, float64, time.Time, civil.Date, big.Rat,
+		*string, *int64, *bool, *float64, *time.Time, *civil.Date,
+		[]string, [][]byte, []int, []int64, []bool, []float64, []time.Time, []civil.Date, []big.Rat,
+		[]*string, []*int64, []*bool, []*float64, []*time.Time, []*civil.Date, []*big.Rat,
+		spanner.NullString, spanner.NullInt64, spanner.NullBool, spanner.NullFloat64,
+		spanner.NullTime, spanner.NullDate, spanner.NullNumeric, spanner.NullJSON,
+		[]spanner.NullString, []spanner.NullInt64, []spanner.NullBool, []spanner.NullFloat64,
+		[]spanner.NullTime, []spanner.NullDate, []spanner.NullNumeric, []spanner.NullJSON,
+		spanner.GenericColumnValue:
+		return v, nil
+	case *big.Rat:
+		if v == nil {
+			return spanner.NullNumeric{}, nil
+		}
+		return v, nil
+	case int:
+		return int64(v), nil
+	case int8:
+		return int64(v), nil
+	case int16:
+		return int64(v), nil
+	case int32:
+		return int64(v), nil
+	case uint8:
+		return int64(v), nil
+	case uint16:
+		return int64(v), nil
+	case uint32:
+		return int64(v), nil
+	case uint:
+		return convertUint(uint64(v))
+	case uint64:
+		return convertUint(v)
+	case float32:
+		return float64(v), nil
+
+	case sql.NullString:
+		return spanner.NullString{StringVal: v.String, Valid: v.Valid}, nil
+	case sql.NullInt64:
+		return spanner.NullInt64{Int64: v.Int64, Valid: v.Valid}, nil
+	case sql.NullInt32:
+		return spanner.NullInt64{Int64: int64(v.Int32), Valid: v.Valid}, nil
+	case sql.NullInt16:
+		return spanner.NullInt64{Int64: int64(v.Int16), Valid: v.Valid}, nil
+	case sql.NullByte:
+		return spanner.NullInt64{Int64: int64(v.Byte), Valid: v.Valid}, nil
+	case sql.NullFloat64:
+		return spanner.NullFloat64{Float64: v.Float64, Valid: v.Valid}, nil
+	case sql.NullBool:
+		return spanner.NullBool{Bool: v.Bool, Valid: v.Valid}, nil
+	case sql.NullTime:
+		return spanner.NullTime{Time: v.Time, Valid: v.Valid}, nil
+
+	case NullNumeric:
+		return spanner.NullNumeric{Numeric: v.Numeric, Valid: v.Valid}, nil
+	case Numeric:
+		r, ok := new(big.Rat).SetString(string(v))
+		if !ok {
+			return nil, errors.Errorf("invalid NUMERIC value: %q", string(v))
+		}
+		return r, nil
+	case json.RawMessage:
+		if v == nil {
+			return spanner.NullJSON{}, nil
+		}
+		return spanner.NullJSON{Value: v, Valid: true}, nil
+	case JSON:
+		return spanner.NullJSON{Value: v.Value, Valid: true}, nil
+	case NullJSON:
+		return spanner.NullJSON{Value: v.Value, Valid: v.Valid}, nil
+	}
+
+	if vr, ok := v.(driver.Valuer); ok {
+		value, err := callValuer(vr)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := value.(driver.Valuer); ok {
+			return nil, errors.Errorf("%T.Value returned another driver.Valuer %T", v, value)
+		}
+		return convertValue(value)
+	}
+	if isStructParam(v) {
+		return v, nil
+	}
+	return convertReflectValue(v)
+}
+
+func convertUint(v uint64) (interface{}, error) {
+	if v > math.MaxInt64 {
+		return nil, errors.Errorf("uint64 value %d overflows INT64", v)
+	}
+	return int64(v), nil
+}
+
+// callValuer calls vr.Value, returning nil for a nil pointer whose Value
+// method has a value receiver, as database/sql does.
+func callValuer(vr driver.Valuer) (driver.Value, error) {
+	if rv := reflect.ValueOf(vr); rv.Kind() == reflect.Ptr && rv.IsNil() && rv.Type().Elem().Implements(valuerType) {
+		return nil, nil
+	}
+	return vr.Value()
+}
+
+// convertReflectValue converts values of named types, such as StringArray or
+// `type Status string`, and pointers into the types supported by the spanner client.
+func convertReflectValue(v interface{}) (interface{}, error) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return nil, nil
+		}
+		return convertValue(rv.Elem().Interface())
+	case reflect.String:
+		return rv.String(), nil
+	case reflect.Bool:
+		return rv.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return convertUint(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), nil
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return rv.Bytes(), nil
+		}
+		// A named slice type is converted into its unnamed slice type first,
+		// which is either supported or converted element by element below.
+		if st := reflect.SliceOf(rv.Type().Elem()); st != rv.Type() {
+			return convertValue(rv.Convert(st).Interface())
+		}
+		return convertSlice(rv)
+	}
+	return nil, errors.Errorf("unsupported parameter type %T", v)
+}
+
+// convertSlice converts a slice of a named basic type, e.g. []Status,
+// into a slice of the corresponding basic type.
+func convertSlice(rv reflect.Value) (interface{}, error) {
+	n := rv.Len()
+	switch rv.Type().Elem().Kind() {
+	case reflect.String:
+		a := make([]string, n)
+		for i := range a {
+			a[i] = rv.Index(i).String()
+		}
+		return a, nil
+	case reflect.Bool:
+		a := make([]bool, n)
+		for i := range a {
+			a[i] = rv.Index(i).Bool()
+		}
+		return a, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		a := make([]int64, n)
+		for i := range a {
+			a[i] = rv.Index(i).Int()
+		}
+		return a, nil
+	case reflect.Uint, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		a := make([]int64, n)
+		for i := range a {
+			u := rv.Index(i).Uint()
+			if u > math.MaxInt64 {
+				return nil, errors.Errorf("uint64 value %d overflows INT64", u)
+			}
+			a[i] = int64(u)
+		}
+		return a, nil
+	case reflect.Float32, reflect.Float64:
+		a := make([]float64, n)
+		for i := range a {
+			a[i] = rv.Index(i).Float()
+		}
+		return a, nil
+	}
+	return nil, errors.Errorf("unsupported parameter type %s", rv.Type())
+}
+
+var (
+	valuerType = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+	timeType   = reflect.TypeOf(time.Time{})
+	dateType   = reflect.TypeOf(civil.Date{})
+	ratType    = reflect.TypeOf(big.Rat{})
+)
+
+// isStructParam reports whether v is a Go struct, a pointer to a struct or a
+// slice of those which the spanner client encodes as a STRUCT or ARRAY<STRUCT>
+// parameter, e.g. for use with UNNEST(@rows).
+func isStructParam(v interface{}) bool {
+	t := reflect.TypeOf(v)
+	if t == nil {
+		return false
+	}
+	if t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	switch t {
+	case timeType, dateType, ratType:
+		return false
+	}
+	return !t.Implements(valuerType) && !reflect.PtrTo(t).Implements(valuerType)
+}
+
+func namedValues(args []driver.Value) []driver.NamedValue {
+	nvs := make([]driver.NamedValue, len(args))
+	for i, v := range args {
+		nvs[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+	}
+	return nvs
+}
+
+// prepareSpannerStmt builds a spanner.Statement from the query q and its
+// arguments. Positional parameters '?' are rewritten to @p1, @p2, ..., @pN,
+// so that queries written for other drivers can be used as they are.
 func prepareSpannerStmt(q string, args []driver.NamedValue) (spanner.Statement, error) {
+	q, err := internal.RewritePositionalParams(q)
+	if err != nil {
+		return spanner.Statement{}, err
+	}
 	names, err := internal.NamedValueParamNames(q, len(args))
 	if err != nil {
 		return spanner.Statement{}, err
@@ -1,7 +1,17 @@
 package spannerdriver
 
 import (
+	"database/sql"
 	"database/sql/driver"
+	"encoding/json"
+	"math"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"cloud.google.com/go/spanner"
 )
 
 // static interface implementation checks of mysqlStmt
@@ -11,3 +21,193 @@ var (
 	_ driver.StmtQueryContext  = &spannerStmt{}
 	_ driver.NamedValueChecker = &spannerStmt{}
 )
+
+func TestCheckNamedValueNumeric(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		want  interface{}
+	}{
+		{"big.Rat", big.NewRat(1, 2), big.NewRat(1, 2)},
+		{"nil big.Rat", (*big.Rat)(nil), spanner.NullNumeric{}},
+		{"NullNumeric", NullNumeric{Numeric: *big.NewRat(1, 2), Valid: true}, spanner.NullNumeric{Numeric: *big.NewRat(1, 2), Valid: true}},
+		{"Numeric", Numeric("0.5"), big.NewRat(1, 2)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nv := &driver.NamedValue{Ordinal: 1, Value: tt.value}
+			if err := checkNamedValue(nv); err != nil {
+				t.Fatalf("checkNamedValue returned error: %+v", err)
+			}
+			switch want := tt.want.(type) {
+			case *big.Rat:
+				if got, ok := nv.Value.(*big.Rat); !ok || got.Cmp(want) != 0 {
+					t.Errorf("expected %v, got %#v", want, nv.Value)
+				}
+			case spanner.NullNumeric:
+				got, ok := nv.Value.(spanner.NullNumeric)
+				if !ok || got.Valid != want.Valid || got.Numeric.Cmp(&want.Numeric) != 0 {
+					t.Errorf("expected %v, got %#v", want, nv.Value)
+				}
+			}
+		})
+	}
+
+	if err := checkNamedValue(&driver.NamedValue{Ordinal: 1, Value: Numeric("abc")}); err == nil {
+		t.Error("expected error for invalid Numeric, got nil")
+	}
+}
+
+func TestCheckNamedValueJSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		want  spanner.NullJSON
+	}{
+		{"RawMessage", json.RawMessage(`{"a":1}`), spanner.NullJSON{Value: json.RawMessage(`{"a":1}`), Valid: true}},
+		{"nil RawMessage", json.RawMessage(nil), spanner.NullJSON{}},
+		{"JSON", JSON{Value: []int{1}}, spanner.NullJSON{Value: []int{1}, Valid: true}},
+		{"NullJSON", NullJSON{}, spanner.NullJSON{}},
+		{"spanner.NullJSON", spanner.NullJSON{Value: "a", Valid: true}, spanner.NullJSON{Value: "a", Valid: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nv := &driver.NamedValue{Ordinal: 1, Value: tt.value}
+			if err := checkNamedValue(nv); err != nil {
+				t.Fatalf("checkNamedValue returned error: %+v", err)
+			}
+			if !reflect.DeepEqual(nv.Value, tt.want) {
+				t.Errorf("expected %#v, got %#v", tt.want, nv.Value)
+			}
+		})
+	}
+}
+
+func TestCheckNamedValueStruct(t *testing.T) {
+	type item struct {
+		Name string
+	}
+	for _, v := range []interface{}{item{}, &item{}, []item{}, []*item{}} {
+		if err := checkNamedValue(&driver.NamedValue{Ordinal: 1, Value: v}); err != nil {
+			t.Errorf("%T: checkNamedValue returned error: %+v", v, err)
+		}
+	}
+	// Struct types with their own encoding must not be sent as STRUCT.
+	for _, v := range []interface{}{time.Time{}, spanner.NullString{}, sql.NullInt64{}} {
+		if isStructParam(v) {
+			t.Errorf("%T: expected not to be a STRUCT parameter", v)
+		}
+	}
+}
+
+type testStatus string
+
+type testValuer struct {
+	v int
+}
+
+func (v testValuer) Value() (driver.Value, error) {
+	return int64(v.v), nil
+}
+
+func TestCheckNamedValue(t *testing.T) {
+	date := civil.Date{Year: 2021, Month: 11, Day: 4}
+	str := "a"
+	tests := []struct {
+		name  string
+		value interface{}
+		want  interface{}
+	}{
+		{"nil", nil, nil},
+		{"string", "a", "a"},
+		{"int", 1, int64(1)},
+		{"int32", int32(1), int64(1)},
+		{"uint64", uint64(1), int64(1)},
+		{"float32", float32(1.5), 1.5},
+		{"civil.Date", date, date},
+		{"pointer", &str, &str},
+		{"nil pointer", (*int)(nil), nil},
+		{"pointer to int", func() *int { i := 1; return &i }(), int64(1)},
+		{"[]string", []string{"a"}, []string{"a"}},
+		{"[]int32", []int32{1, 2}, []int64{1, 2}},
+		{"StringArray", StringArray{"a"}, []string{"a"}},
+		{"NullInt64Array", NullInt64Array{{}}, []spanner.NullInt64{{}}},
+		{"named string", testStatus("ok"), "ok"},
+		{"[]named string", []testStatus{"ok"}, []string{"ok"}},
+		{"sql.NullString", sql.NullString{String: "a", Valid: true}, spanner.NullString{StringVal: "a", Valid: true}},
+		{"sql.NullInt32", sql.NullInt32{}, spanner.NullInt64{}},
+		{"sql.NullTime", sql.NullTime{}, spanner.NullTime{}},
+		{"spanner.NullString", spanner.NullString{StringVal: "a", Valid: true}, spanner.NullString{StringVal: "a", Valid: true}},
+		{"driver.Valuer", testValuer{v: 1}, int64(1)},
+		{"nil driver.Valuer", (*testValuer)(nil), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nv := &driver.NamedValue{Ordinal: 1, Value: tt.value}
+			if err := checkNamedValue(nv); err != nil {
+				t.Fatalf("checkNamedValue returned error: %+v", err)
+			}
+			if !reflect.DeepEqual(nv.Value, tt.want) {
+				t.Errorf("expected %#v, got %#v", tt.want, nv.Value)
+			}
+		})
+	}
+}
+
+func TestCheckNamedValueError(t *testing.T) {
+	for _, v := range []interface{}{
+		uint64(math.MaxUint64),
+		[]uint64{math.MaxUint64},
+		map[string]string{},
+		[]interface{}{1},
+		make(chan int),
+		Numeric("abc"),
+	} {
+		if err := checkNamedValue(&driver.NamedValue{Ordinal: 1, Value: v}); err == nil {
+			t.Errorf("%T: expected error, got nil", v)
+		}
+	}
+}
+
+func TestPrepareSpannerStmt(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		args  []driver.NamedValue
+		want  spanner.Statement
+	}{
+		{
+			name:  "named",
+			query: "SELECT * FROM Users WHERE email = 'me@example.com' AND id = @id",
+			args:  []driver.NamedValue{{Ordinal: 1, Value: int64(1)}},
+			want: spanner.Statement{
+				SQL:    "SELECT * FROM Users WHERE email = 'me@example.com' AND id = @id",
+				Params: map[string]interface{}{"id": int64(1)},
+			},
+		},
+		{
+			name:  "positional",
+			query: "SELECT * FROM Users WHERE id = ? AND name = ? AND note = '?'",
+			args:  []driver.NamedValue{{Ordinal: 1, Value: int64(1)}, {Ordinal: 2, Value: "a"}},
+			want: spanner.Statement{
+				SQL:    "SELECT * FROM Users WHERE id = @p1 AND name = @p2 AND note = '?'",
+				Params: map[string]interface{}{"p1": int64(1), "p2": "a"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := prepareSpannerStmt(tt.query, tt.args)
+			if err != nil {
+				t.Fatalf("prepareSpannerStmt returned error: %+v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %#v, got %#v", tt.want, got)
+			}
+		})
+	}
+
+	if _, err := prepareSpannerStmt("SELECT ?, @a", []driver.NamedValue{{Ordinal: 1, Value: int64(1)}}); err == nil {
+		t.Error("expected error for mixed parameters, got nil")
+	}
+}
@@ -2,6 +2,12 @@ package spannerdriver
 
 import (
 	"context"
+	"database/sql"
+	"math/rand"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"github.com/pkg/errors"
 )
 
 type rwTx struct {
@@ -20,7 +26,18 @@ func (tx *rwTx) Commit() (err error) {
 	if tx.conn == nil || tx.conn.rwTx == nil || tx.conn.closed.IsSet() {
 		return ErrInvalidConn
 	}
-	_, err = tx.conn.rwTx.Commit(tx.ctx)
+	if tx.conn.batch != nil {
+		return errors.New("cannot commit while a batch is active, run or abort the batch first")
+	}
+	// A failed commit has no commit timestamp.
+	tx.conn.commitTimestamp = time.Time{}
+	var commitTimestamp time.Time
+	commitTimestamp, err = tx.conn.rwTx.Commit(tx.ctx)
+	if err == nil {
+		tx.conn.commitTimestamp = commitTimestamp
+	} else {
+		err = tx.conn.markBadConn(err)
+	}
 	tx.close()
 	tx.conn = nil
 	return
@@ -31,6 +48,7 @@ func (tx *rwTx) Rollback() (err error) {
 		return ErrInvalidConn
 	}
 	tx.conn.rwTx.Rollback(context.Background())
+	tx.conn.batch = nil
 	tx.close()
 	tx.conn = nil
 	return
@@ -53,3 +71,63 @@ func (tx *roTx) Rollback() (err error) {
 	tx.conn = nil
 	return
 }
+
+// maxTransactionAttempts is the maximum number of times RunTransaction runs a
+// function before the abort of the last attempt is returned.
+const maxTransactionAttempts = 10
+
+// RunTransaction runs fn in a transaction begun on db with opts, and commits
+// the transaction if fn returns nil. If fn or the commit fails because Cloud
+// Spanner aborted the transaction, the transaction is rolled back and fn is run
+// again in a new transaction, after the retry delay returned by Cloud Spanner
+// or an exponential backoff. fn must not have side effects outside the
+// transaction, as it can be run more than once.
+func RunTransaction(ctx context.Context, db *sql.DB, opts *sql.TxOptions, fn func(*sql.Tx) error) error {
+	var err error
+	for attempt := 0; attempt < maxTransactionAttempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(retryDelay(err, attempt)):
+			case <-ctx.Done():
+				return wrapError(ctx.Err())
+			}
+		}
+		err = runTransaction(ctx, db, opts, fn)
+		if !IsRetryable(err) {
+			return err
+		}
+	}
+	return err
+}
+
+func runTransaction(ctx context.Context, db *sql.DB, opts *sql.TxOptions, fn func(*sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, opts)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// retryDelay returns the delay before retrying an aborted transaction, which
+// is the delay returned by Cloud Spanner in err if any, or else an
+// exponential backoff with jitter for the attempt.
+func retryDelay(err error, attempt int) time.Duration {
+	if delay, ok := spanner.ExtractRetryDelay(err); ok {
+		return delay
+	}
+	const (
+		initialDelay = 20 * time.Millisecond
+		maxDelay     = 5 * time.Second
+	)
+	delay := maxDelay
+	if attempt < 16 {
+		if d := initialDelay << uint(attempt-1); d < maxDelay {
+			delay = d
+		}
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
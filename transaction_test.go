@@ -1,7 +1,13 @@
 package spannerdriver
 
 import (
+	"context"
 	"database/sql/driver"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // static interface implementation checks of mysqlStmt
@@ -9,3 +15,42 @@ var (
 	_ driver.Tx = &rwTx{}
 	_ driver.Tx = &roTx{}
 )
+
+func TestRetryDelay(t *testing.T) {
+	aborted := status.Error(codes.Aborted, "aborted")
+	for attempt, max := 1, 20*time.Millisecond; attempt < 100; attempt, max = attempt+1, max*2 {
+		if max > 5*time.Second {
+			max = 5 * time.Second
+		}
+		if d := retryDelay(aborted, attempt); d < max/2 || d > max {
+			t.Errorf("attempt %d: expected delay between %s and %s, got %s", attempt, max/2, max, d)
+		}
+	}
+}
+
+func TestCommitTimestampAfterFailedCommit(t *testing.T) {
+	ctx := context.Background()
+	c := newTestConn()
+	c.commitTimestamp = time.Date(2021, 11, 4, 10, 0, 0, 0, time.UTC)
+
+	db := &fakeDatabase{abortedCommits: 1}
+	fake, _ := db.begin(ctx)
+	c.rwTx = &readWriteTransaction{tx: fake}
+	tx := &rwTx{ctx: ctx, conn: c, close: func() { c.rwTx = nil }}
+	if err := tx.Commit(); err == nil {
+		t.Fatal("expected the commit to fail, got nil")
+	}
+	if ts, err := c.CommitTimestamp(); err == nil {
+		t.Errorf("expected no commit timestamp after a failed commit, got %s", ts)
+	}
+
+	fake, _ = db.begin(ctx)
+	c.rwTx = &readWriteTransaction{tx: fake}
+	tx = &rwTx{ctx: ctx, conn: c, close: func() { c.rwTx = nil }}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if ts, err := c.CommitTimestamp(); err != nil || !ts.Equal(time.Unix(1, 0)) {
+		t.Errorf("expected the commit timestamp of the fake transaction, got %s, %v", ts, err)
+	}
+}
@@ -0,0 +1,554 @@
+package spannerdriver
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"cloud.google.com/go/spanner"
+	"github.com/pkg/errors"
+)
+
+// NullNumeric represents a NUMERIC value that may be NULL.
+// It can be used both as a scan destination and as a query parameter.
+type NullNumeric struct {
+	Numeric big.Rat
+	Valid   bool // Valid is true if Numeric is not NULL
+}
+
+// Scan implements the database/sql.Scanner interface.
+func (n *NullNumeric) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		n.Numeric, n.Valid = big.Rat{}, false
+		return nil
+	case string:
+		return n.setString(v)
+	case []byte:
+		return n.setString(string(v))
+	case *big.Rat:
+		// Set copies the value, which a plain assignment would share with v.
+		n.Numeric.Set(v)
+		n.Valid = true
+		return nil
+	}
+	return errors.Errorf("cannot scan %T into %T", src, n)
+}
+
+func (n *NullNumeric) setString(s string) error {
+	if _, ok := n.Numeric.SetString(s); !ok {
+		n.Numeric, n.Valid = big.Rat{}, false
+		return errors.Errorf("invalid NUMERIC value: %q", s)
+	}
+	n.Valid = true
+	return nil
+}
+
+// Value implements the database/sql/driver.Valuer interface.
+func (n NullNumeric) Value() (driver.Value, error) {
+	if !n.Valid {
+		return nil, nil
+	}
+	return spanner.NumericString(&n.Numeric), nil
+}
+
+// Numeric is a NUMERIC value in its decimal string form, e.g. "123.45".
+// Binding a plain string sends a STRING parameter, so wrap decimal strings
+// with Numeric to bind them as NUMERIC.
+type Numeric string
+
+// JSON scans a JSON column by unmarshaling it into Value, and binds Value as a
+// JSON parameter. Set Value to a pointer before scanning to unmarshal into a
+// specific Go type, otherwise the document is unmarshaled into an interface{}.
+// Scanning a NULL value returns an error, use NullJSON for nullable columns.
+type JSON struct {
+	Value interface{}
+}
+
+// Scan implements the database/sql.Scanner interface.
+func (j *JSON) Scan(src interface{}) error {
+	if src == nil {
+		return errors.Errorf("cannot scan NULL into %T", j)
+	}
+	return unmarshalJSON(src, &j.Value)
+}
+
+// NullJSON represents a JSON value that may be NULL.
+// It behaves like JSON, except that NULL is scanned by setting Valid to false,
+// and Value to nil unless it is a pointer set by the caller.
+type NullJSON struct {
+	Value interface{}
+	Valid bool // Valid is true if Value is not NULL
+}
+
+// Scan implements the database/sql.Scanner interface.
+func (n *NullJSON) Scan(src interface{}) error {
+	if src == nil {
+		n.Valid = false
+		if !isNonNilPointer(n.Value) {
+			n.Value = nil
+		}
+		return nil
+	}
+	if err := unmarshalJSON(src, &n.Value); err != nil {
+		return err
+	}
+	n.Valid = true
+	return nil
+}
+
+// unmarshalJSON unmarshals src into *dest when it holds a non-nil pointer,
+// or replaces *dest with the decoded document otherwise, e.g. the document of
+// the previous row when the same value is scanned again.
+func unmarshalJSON(src interface{}, dest *interface{}) error {
+	var b []byte
+	switch v := src.(type) {
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return errors.Errorf("cannot scan %T into JSON", src)
+	}
+	if isNonNilPointer(*dest) {
+		return json.Unmarshal(b, *dest)
+	}
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	*dest = v
+	return nil
+}
+
+func isNonNilPointer(v interface{}) bool {
+	rv := reflect.ValueOf(v)
+	return rv.Kind() == reflect.Ptr && !rv.IsNil()
+}
+
+// Struct scans a STRUCT or ARRAY<STRUCT> column into Value.
+//
+// For a STRUCT column Value must be a pointer to a Go struct, a pointer to a
+// pointer to a Go struct or a pointer to a map[string]interface{}. For an
+// ARRAY<STRUCT> column Value must be a pointer to a slice of those.
+// Go struct fields are matched by name or by the `spanner` field tag,
+// the same way as spanner.Row.ToStruct does.
+// A NULL STRUCT can only be scanned into a pointer or a map, which is set to nil.
+type Struct struct {
+	Value interface{}
+}
+
+var mapType = reflect.TypeOf(map[string]interface{}{})
+
+// Scan implements the database/sql.Scanner interface.
+func (s *Struct) Scan(src interface{}) error {
+	dv := reflect.ValueOf(s.Value)
+	if dv.Kind() != reflect.Ptr || dv.IsNil() {
+		return errors.Errorf("Struct.Value must be a non-nil pointer, got %T", s.Value)
+	}
+	dv = dv.Elem()
+
+	switch v := src.(type) {
+	case nil:
+		if dv.Kind() == reflect.Slice {
+			dv.Set(reflect.Zero(dv.Type()))
+			return nil
+		}
+		return scanRow(nil, dv)
+	case *spanner.Row:
+		return scanRow(v, dv)
+	case []*spanner.Row:
+		if dv.Kind() != reflect.Slice {
+			return errors.Errorf("cannot scan ARRAY<STRUCT> into %T", s.Value)
+		}
+		sv := reflect.MakeSlice(dv.Type(), len(v), len(v))
+		for i, row := range v {
+			if err := scanRow(row, sv.Index(i)); err != nil {
+				return err
+			}
+		}
+		dv.Set(sv)
+		return nil
+	}
+	return errors.Errorf("cannot scan %T into %T", src, s.Value)
+}
+
+// scanRow decodes row into dv, which must be a struct, a pointer to a struct
+// or a map[string]interface{}. A nil row is a NULL STRUCT.
+func scanRow(row *spanner.Row, dv reflect.Value) error {
+	if row == nil {
+		if dv.Kind() != reflect.Ptr && dv.Kind() != reflect.Map {
+			return errors.Errorf("cannot scan NULL STRUCT into %s", dv.Type())
+		}
+		dv.Set(reflect.Zero(dv.Type()))
+		return nil
+	}
+
+	switch {
+	case dv.Kind() == reflect.Struct:
+		return row.ToStruct(dv.Addr().Interface())
+	case dv.Kind() == reflect.Ptr && dv.Type().Elem().Kind() == reflect.Struct:
+		p := reflect.New(dv.Type().Elem())
+		if err := row.ToStruct(p.Interface()); err != nil {
+			return err
+		}
+		dv.Set(p)
+		return nil
+	case dv.Type() == mapType:
+		m := make(map[string]interface{}, row.Size())
+		for i := 0; i < row.Size(); i++ {
+			var col spanner.GenericColumnValue
+			if err := row.Column(i, &col); err != nil {
+				return err
+			}
+			v, err := decodeColumn(col)
+			if err != nil {
+				return err
+			}
+			m[row.ColumnName(i)] = v
+		}
+		dv.Set(reflect.ValueOf(m))
+		return nil
+	}
+	return errors.Errorf("cannot scan STRUCT into %s", dv.Type())
+}
+
+// The array types in this file implement the database/sql.Scanner interface
+// for ARRAY columns. The Null* variants preserve NULL elements, the others
+// return an error when the array contains a NULL element.
+// A NULL array is scanned as a nil slice.
+
+// BoolArray scans an ARRAY<BOOL> column.
+type BoolArray []bool
+
+// Scan implements the database/sql.Scanner interface.
+func (a *BoolArray) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*a = nil
+		return nil
+	case []spanner.NullBool:
+		s := make(BoolArray, len(v))
+		for i, e := range v {
+			if !e.Valid {
+				return errScanNullElement(a, i)
+			}
+			s[i] = e.Bool
+		}
+		*a = s
+		return nil
+	}
+	return errScanArray(a, src)
+}
+
+// NullBoolArray scans an ARRAY<BOOL> column that may contain NULL elements.
+type NullBoolArray []spanner.NullBool
+
+// Scan implements the database/sql.Scanner interface.
+func (a *NullBoolArray) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*a = nil
+		return nil
+	case []spanner.NullBool:
+		*a = v
+		return nil
+	}
+	return errScanArray(a, src)
+}
+
+// Int64Array scans an ARRAY<INT64> column.
+type Int64Array []int64
+
+// Scan implements the database/sql.Scanner interface.
+func (a *Int64Array) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*a = nil
+		return nil
+	case []spanner.NullInt64:
+		s := make(Int64Array, len(v))
+		for i, e := range v {
+			if !e.Valid {
+				return errScanNullElement(a, i)
+			}
+			s[i] = e.Int64
+		}
+		*a = s
+		return nil
+	}
+	return errScanArray(a, src)
+}
+
+// NullInt64Array scans an ARRAY<INT64> column that may contain NULL elements.
+type NullInt64Array []spanner.NullInt64
+
+// Scan implements the database/sql.Scanner interface.
+func (a *NullInt64Array) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*a = nil
+		return nil
+	case []spanner.NullInt64:
+		*a = v
+		return nil
+	}
+	return errScanArray(a, src)
+}
+
+// Float64Array scans an ARRAY<FLOAT64> column.
+type Float64Array []float64
+
+// Scan implements the database/sql.Scanner interface.
+func (a *Float64Array) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*a = nil
+		return nil
+	case []spanner.NullFloat64:
+		s := make(Float64Array, len(v))
+		for i, e := range v {
+			if !e.Valid {
+				return errScanNullElement(a, i)
+			}
+			s[i] = e.Float64
+		}
+		*a = s
+		return nil
+	}
+	return errScanArray(a, src)
+}
+
+// NullFloat64Array scans an ARRAY<FLOAT64> column that may contain NULL elements.
+type NullFloat64Array []spanner.NullFloat64
+
+// Scan implements the database/sql.Scanner interface.
+func (a *NullFloat64Array) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*a = nil
+		return nil
+	case []spanner.NullFloat64:
+		*a = v
+		return nil
+	}
+	return errScanArray(a, src)
+}
+
+// StringArray scans an ARRAY<STRING> column.
+type StringArray []string
+
+// Scan implements the database/sql.Scanner interface.
+func (a *StringArray) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*a = nil
+		return nil
+	case []spanner.NullString:
+		s := make(StringArray, len(v))
+		for i, e := range v {
+			if !e.Valid {
+				return errScanNullElement(a, i)
+			}
+			s[i] = e.StringVal
+		}
+		*a = s
+		return nil
+	}
+	return errScanArray(a, src)
+}
+
+// NullStringArray scans an ARRAY<STRING> column that may contain NULL elements.
+type NullStringArray []spanner.NullString
+
+// Scan implements the database/sql.Scanner interface.
+func (a *NullStringArray) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*a = nil
+		return nil
+	case []spanner.NullString:
+		*a = v
+		return nil
+	}
+	return errScanArray(a, src)
+}
+
+// BytesArray scans an ARRAY<BYTES> column. NULL elements are scanned as nil.
+type BytesArray [][]byte
+
+// Scan implements the database/sql.Scanner interface.
+func (a *BytesArray) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*a = nil
+		return nil
+	case [][]byte:
+		*a = v
+		return nil
+	}
+	return errScanArray(a, src)
+}
+
+// DateArray scans an ARRAY<DATE> column.
+type DateArray []civil.Date
+
+// Scan implements the database/sql.Scanner interface.
+func (a *DateArray) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*a = nil
+		return nil
+	case []spanner.NullDate:
+		s := make(DateArray, len(v))
+		for i, e := range v {
+			if !e.Valid {
+				return errScanNullElement(a, i)
+			}
+			s[i] = e.Date
+		}
+		*a = s
+		return nil
+	}
+	return errScanArray(a, src)
+}
+
+// NullDateArray scans an ARRAY<DATE> column that may contain NULL elements.
+type NullDateArray []spanner.NullDate
+
+// Scan implements the database/sql.Scanner interface.
+func (a *NullDateArray) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*a = nil
+		return nil
+	case []spanner.NullDate:
+		*a = v
+		return nil
+	}
+	return errScanArray(a, src)
+}
+
+// TimeArray scans an ARRAY<TIMESTAMP> column.
+type TimeArray []time.Time
+
+// Scan implements the database/sql.Scanner interface.
+func (a *TimeArray) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*a = nil
+		return nil
+	case []spanner.NullTime:
+		s := make(TimeArray, len(v))
+		for i, e := range v {
+			if !e.Valid {
+				return errScanNullElement(a, i)
+			}
+			s[i] = e.Time
+		}
+		*a = s
+		return nil
+	}
+	return errScanArray(a, src)
+}
+
+// NullTimeArray scans an ARRAY<TIMESTAMP> column that may contain NULL elements.
+type NullTimeArray []spanner.NullTime
+
+// Scan implements the database/sql.Scanner interface.
+func (a *NullTimeArray) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*a = nil
+		return nil
+	case []spanner.NullTime:
+		*a = v
+		return nil
+	}
+	return errScanArray(a, src)
+}
+
+// NumericArray scans an ARRAY<NUMERIC> column.
+type NumericArray []big.Rat
+
+// Scan implements the database/sql.Scanner interface.
+func (a *NumericArray) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*a = nil
+		return nil
+	case []spanner.NullNumeric:
+		s := make(NumericArray, len(v))
+		for i, e := range v {
+			if !e.Valid {
+				return errScanNullElement(a, i)
+			}
+			s[i] = e.Numeric
+		}
+		*a = s
+		return nil
+	}
+	return errScanArray(a, src)
+}
+
+// NullNumericArray scans an ARRAY<NUMERIC> column that may contain NULL elements.
+type NullNumericArray []spanner.NullNumeric
+
+// Scan implements the database/sql.Scanner interface.
+func (a *NullNumericArray) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*a = nil
+		return nil
+	case []spanner.NullNumeric:
+		*a = v
+		return nil
+	}
+	return errScanArray(a, src)
+}
+
+// NullJSONArray scans an ARRAY<JSON> column that may contain NULL elements.
+// The elements are the raw JSON documents, and NULL elements are scanned as nil.
+type NullJSONArray []json.RawMessage
+
+// Scan implements the database/sql.Scanner interface.
+func (a *NullJSONArray) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*a = nil
+		return nil
+	case []json.RawMessage:
+		*a = v
+		return nil
+	}
+	return errScanArray(a, src)
+}
+
+// RowArray scans an ARRAY<STRUCT> column into the rows of its elements, which
+// can be decoded with spanner.Row.ToStruct. NULL elements are scanned as nil.
+// Use Struct to scan the column into a slice of Go structs.
+type RowArray []*spanner.Row
+
+// Scan implements the database/sql.Scanner interface.
+func (a *RowArray) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*a = nil
+		return nil
+	case []*spanner.Row:
+		*a = v
+		return nil
+	}
+	return errScanArray(a, src)
+}
+
+func errScanArray(dest interface{}, src interface{}) error {
+	return errors.Errorf("cannot scan %T into %T", src, dest)
+}
+
+func errScanNullElement(dest interface{}, index int) error {
+	return errors.Errorf("cannot scan NULL element at index %d into %T", index, dest)
+}
@@ -0,0 +1,212 @@
+package spannerdriver
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+
+	"cloud.google.com/go/spanner"
+)
+
+func TestStringArrayScan(t *testing.T) {
+	var a StringArray
+	if err := a.Scan([]spanner.NullString{{StringVal: "a", Valid: true}, {StringVal: "b", Valid: true}}); err != nil {
+		t.Fatalf("Scan returned error: %+v", err)
+	}
+	if want := (StringArray{"a", "b"}); !reflect.DeepEqual(a, want) {
+		t.Errorf("expected %v, got %v", want, a)
+	}
+
+	if err := a.Scan([]spanner.NullString{{StringVal: "a", Valid: true}, {}}); err == nil {
+		t.Error("expected error for NULL element, got nil")
+	}
+
+	if err := a.Scan(nil); err != nil {
+		t.Fatalf("Scan returned error: %+v", err)
+	}
+	if a != nil {
+		t.Errorf("expected nil, got %v", a)
+	}
+
+	if err := a.Scan([]spanner.NullInt64{}); err == nil {
+		t.Error("expected error for mismatched array type, got nil")
+	}
+}
+
+func TestNullInt64ArrayScan(t *testing.T) {
+	var a NullInt64Array
+	src := []spanner.NullInt64{{Int64: 1, Valid: true}, {}}
+	if err := a.Scan(src); err != nil {
+		t.Fatalf("Scan returned error: %+v", err)
+	}
+	if !reflect.DeepEqual([]spanner.NullInt64(a), src) {
+		t.Errorf("expected %v, got %v", src, a)
+	}
+
+	var b Int64Array
+	if err := b.Scan(src); err == nil {
+		t.Error("expected error for NULL element, got nil")
+	}
+}
+
+func TestNullNumericScan(t *testing.T) {
+	var n NullNumeric
+	if err := n.Scan("123.450000000"); err != nil {
+		t.Fatalf("Scan returned error: %+v", err)
+	}
+	if !n.Valid || n.Numeric.Cmp(big.NewRat(12345, 100)) != 0 {
+		t.Errorf("unexpected value: %v", n)
+	}
+
+	v, err := n.Value()
+	if err != nil {
+		t.Fatalf("Value returned error: %+v", err)
+	}
+	if want := "123.450000000"; v != want {
+		t.Errorf("expected %q, got %#v", want, v)
+	}
+
+	if err := n.Scan(nil); err != nil {
+		t.Fatalf("Scan returned error: %+v", err)
+	}
+	if n.Valid {
+		t.Errorf("expected NULL, got %v", n)
+	}
+
+	if err := n.Scan("1.5"); err != nil {
+		t.Fatalf("Scan returned error: %+v", err)
+	}
+	if err := n.Scan("not a number"); err == nil {
+		t.Error("expected error for invalid NUMERIC value, got nil")
+	}
+	if n.Valid {
+		t.Errorf("expected a failed scan not to keep the previous value, got %v", n)
+	}
+
+	// The scanned value must not share its memory with the source.
+	src := big.NewRat(12345, 100)
+	if err := n.Scan(src); err != nil {
+		t.Fatalf("Scan returned error: %+v", err)
+	}
+	src.SetInt64(1)
+	if !n.Valid || n.Numeric.Cmp(big.NewRat(12345, 100)) != 0 {
+		t.Errorf("expected the value to be copied, got %v", n.Numeric.String())
+	}
+}
+
+func TestJSONScan(t *testing.T) {
+	var v struct {
+		Name string `json:"name"`
+	}
+	j := JSON{Value: &v}
+	if err := j.Scan([]byte(`{"name":"foo"}`)); err != nil {
+		t.Fatalf("Scan returned error: %+v", err)
+	}
+	if v.Name != "foo" {
+		t.Errorf("expected foo, got %s", v.Name)
+	}
+	if err := j.Scan(nil); err == nil {
+		t.Error("expected error for NULL, got nil")
+	}
+
+	var n NullJSON
+	if err := n.Scan([]byte(`[1, 2]`)); err != nil {
+		t.Fatalf("Scan returned error: %+v", err)
+	}
+	if want := []interface{}{1.0, 2.0}; !n.Valid || !reflect.DeepEqual(n.Value, want) {
+		t.Errorf("expected %v, got %v", want, n)
+	}
+	if err := n.Scan(nil); err != nil {
+		t.Fatalf("Scan returned error: %+v", err)
+	}
+	if n.Valid || n.Value != nil {
+		t.Errorf("expected NULL, got %v", n)
+	}
+}
+
+func TestJSONScanReuse(t *testing.T) {
+	// The same value is scanned for every row of a query.
+	var j JSON
+	for _, row := range []string{`{"name":"foo"}`, `{"name":"bar"}`, `[1]`} {
+		if err := j.Scan(row); err != nil {
+			t.Fatalf("%s: Scan returned error: %+v", row, err)
+		}
+	}
+	if want := []interface{}{1.0}; !reflect.DeepEqual(j.Value, want) {
+		t.Errorf("expected %v, got %v", want, j.Value)
+	}
+
+	var n NullJSON
+	if err := n.Scan(`{"name":"foo"}`); err != nil {
+		t.Fatalf("Scan returned error: %+v", err)
+	}
+	if err := n.Scan(`{"name":"bar"}`); err != nil {
+		t.Fatalf("Scan returned error: %+v", err)
+	}
+	if want := map[string]interface{}{"name": "bar"}; !n.Valid || !reflect.DeepEqual(n.Value, want) {
+		t.Errorf("expected %v, got %v", want, n)
+	}
+	if err := n.Scan(nil); err != nil {
+		t.Fatalf("Scan returned error: %+v", err)
+	}
+	if n.Valid || n.Value != nil {
+		t.Errorf("expected NULL to clear the previous value, got %v", n)
+	}
+
+	// A pointer set by the caller is kept for the next rows.
+	var v struct {
+		Name string `json:"name"`
+	}
+	n = NullJSON{Value: &v}
+	for _, row := range []interface{}{`{"name":"foo"}`, nil, `{"name":"bar"}`} {
+		if err := n.Scan(row); err != nil {
+			t.Fatalf("%v: Scan returned error: %+v", row, err)
+		}
+	}
+	if n.Value != &v || v.Name != "bar" {
+		t.Errorf("expected bar to be scanned into the pointer, got %v", n)
+	}
+}
+
+func TestStructScan(t *testing.T) {
+	type item struct {
+		Name  string `spanner:"name"`
+		Count int64  `spanner:"count"`
+	}
+	row, err := spanner.NewRow([]string{"name", "count"}, []interface{}{"a", int64(1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var v item
+	if err := (&Struct{Value: &v}).Scan(row); err != nil {
+		t.Fatalf("Scan returned error: %+v", err)
+	}
+	if want := (item{Name: "a", Count: 1}); v != want {
+		t.Errorf("expected %v, got %v", want, v)
+	}
+
+	var m map[string]interface{}
+	if err := (&Struct{Value: &m}).Scan(row); err != nil {
+		t.Fatalf("Scan returned error: %+v", err)
+	}
+	if want := map[string]interface{}{"name": "a", "count": int64(1)}; !reflect.DeepEqual(m, want) {
+		t.Errorf("expected %v, got %v", want, m)
+	}
+
+	var items []*item
+	if err := (&Struct{Value: &items}).Scan([]*spanner.Row{row, nil}); err != nil {
+		t.Fatalf("Scan returned error: %+v", err)
+	}
+	if len(items) != 2 || *items[0] != v || items[1] != nil {
+		t.Errorf("unexpected value: %v", items)
+	}
+
+	var values []item
+	if err := (&Struct{Value: &values}).Scan([]*spanner.Row{row, nil}); err == nil {
+		t.Error("expected error for NULL element, got nil")
+	}
+	if err := (&Struct{Value: v}).Scan(row); err == nil {
+		t.Error("expected error for non-pointer value, got nil")
+	}
+}